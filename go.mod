@@ -5,5 +5,7 @@ go 1.15
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gorilla/mux v1.8.0
-	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/lib/pq v1.10.0
+	github.com/mattn/go-sqlite3 v1.14.12
+	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 )
@@ -2,14 +2,20 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/makupi/backend-homework/middlewares"
 	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/responses"
 	"github.com/makupi/backend-homework/storage"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,110 +27,656 @@ func getEnv(env, fallback string) string {
 	return fallback
 }
 
-// App contains the apps storage and JWTSecret
+// getEnvInt returns content of env as int if set and valid, fallback if not
+func getEnvInt(env string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(env, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool returns content of env as bool if set and valid, fallback if not
+func getEnvBool(env string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(env, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration returns content of env as time.Duration if set and valid, fallback if not
+func getEnvDuration(env string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(env, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// App contains the apps storage and the config for issuing tokens
+// Questions stay in the trash for TrashRetention before they are purged
+// RequireIfMatch rejects changes of questions without an If-Match header
 type App struct {
-	Storage   storage.Storage
-	JWTSecret []byte
+	Storage        storage.Storage
+	Tokens         storage.TokenConfig
+	TrashRetention time.Duration
+	RequireIfMatch bool
+}
+
+// newStorage returns MemoryStorage if STORAGE is memory, PostgresStorage if DATABASE_URL is set
+// and SqliteStorage at DATABASE_PATH otherwise
+func newStorage(passwords storage.PasswordHasher) (storage.Storage, error) {
+	if getEnv("STORAGE", "") == "memory" {
+		return storage.NewMemoryStorage(passwords), nil
+	}
+	if url := getEnv("DATABASE_URL", ""); url != "" {
+		return storage.NewPostgresStorage(url, passwords)
+	}
+	return storage.NewSqliteStorage(getEnv("DATABASE_PATH", "./db.sqlite3"), passwords)
 }
 
-// Initialize initializes the app with storage and loads the Secret
+// newMigrator returns the Migrator for the database newStorage would use
+func newMigrator() (*storage.Migrator, error) {
+	if getEnv("STORAGE", "") == "memory" {
+		return nil, errors.New("in-memory storage has no migrations")
+	}
+	if url := getEnv("DATABASE_URL", ""); url != "" {
+		return storage.NewPostgresMigrator(url)
+	}
+	return storage.NewSqliteMigrator(getEnv("DATABASE_PATH", "./db.sqlite3"))
+}
+
+// Initialize initializes the app with storage and loads the token config
+// The bcrypt cost for password hashing can be set with BCRYPT_COST
 func (a *App) Initialize() {
-	a.Storage = storage.NewSqliteStorage()
-	a.JWTSecret = []byte(getEnv("JWT_SECRET", "development-secret"))
+	passwords := storage.NewPasswordHasher(getEnvInt("BCRYPT_COST", bcrypt.DefaultCost))
+	var err error
+	a.Storage, err = newStorage(passwords)
+	if err != nil {
+		log.Fatal(err)
+	}
+	a.Tokens = storage.TokenConfig{
+		Secret:      []byte(getEnv("JWT_SECRET", "development-secret")),
+		Issuer:      getEnv("JWT_ISSUER", "backend-homework"),
+		AccessTTL:   getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTTL:  getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		DeliveryTTL: getEnvDuration("DELIVERY_TOKEN_TTL", 24*time.Hour),
+	}
+	a.TrashRetention = getEnvDuration("TRASH_RETENTION", 30*24*time.Hour)
+	a.RequireIfMatch = getEnvBool("REQUIRE_IF_MATCH", false)
 }
 
-func addJSONPayload(w http.ResponseWriter, statusCode int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(payload)
+// purgeTrash permanently deletes questions that are in the trash for longer than TrashRetention every interval
+// It never returns, so it has to run in its own goroutine, interval has to be positive
+func (a *App) purgeTrash(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.purgeExpiredTrash()
+		<-ticker.C
+	}
+}
+
+// purgeExpiredTrash permanently deletes questions that are in the trash for longer than TrashRetention
+func (a *App) purgeExpiredTrash() {
+	purged, err := a.Storage.PurgeDeleted(time.Now().Add(-a.TrashRetention))
 	if err != nil {
 		log.Print(err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d questions from the trash", purged)
 	}
 }
 
-func parseVarFromRequest(w http.ResponseWriter, r *http.Request, key string) (int, error) {
+// parseVarFromRequest parses the route variable key as int
+// If it isn't a valid int it will result in a bad request error
+func parseVarFromRequest(r *http.Request, key string) (int, error) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars[key])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return id, err
+		return id, responses.BadRequest(err)
 	}
 	return id, nil
 }
 
+// writeQuestion writes question as JSON with its version as ETag
+func writeQuestion(w http.ResponseWriter, question models.Question) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(question.Version)))
+	responses.JSON(w, http.StatusOK, question)
+}
+
+// ifMatch returns the version of a question the If-Match header of r requires, 0 if every version matches
+// Only a single ETag or * are supported, without If-Match it fails if RequireIfMatch is set
+func (a *App) ifMatch(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if a.RequireIfMatch {
+			return 0, responses.ErrPreconditionRequired
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, responses.BadRequest(fmt.Errorf("If-Match %s is not an ETag of a question", header))
+	}
+	return version, nil
+}
+
+// conditionally runs fn as a single unit of work if the question id still matches the If-Match header of r
+// If the question changed since it will result in storage.ErrVersionMismatch
+func (a *App) conditionally(r *http.Request, id, userID int, fn func(tx storage.Storage) error) error {
+	version, err := a.ifMatch(r)
+	if err != nil {
+		return err
+	}
+	return a.Storage.Atomic(func(tx storage.Storage) error {
+		if version != 0 {
+			question, err := tx.Get(id, userID)
+			if err != nil {
+				return err
+			}
+			if question.Version != version {
+				return fmt.Errorf("question %d: %w %d", id, storage.ErrVersionMismatch, version)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// decodeJSONBody decodes the request body into v
+// If the body isn't valid JSON it will result in a bad request error
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return responses.BadRequest(err)
+	}
+	return nil
+}
+
+// parsePageRequest parses the query parameters q, sort, limit, cursor, tag, tag_mode, difficulty, min_points,
+// max_points, max_time_limit and updated_since
+// Searches are sorted by relevance unless requested otherwise
+// Limits above MaxPageSize are capped, invalid values will result in a bad request error
+func parsePageRequest(r *http.Request) (models.PageRequest, error) {
+	query := r.URL.Query()
+	request := models.PageRequest{Sort: query.Get("sort"), Limit: models.DefaultPageSize}
+	if q := query.Get("q"); q != "" {
+		search, err := models.ParseSearchQuery(q)
+		if err != nil {
+			return request, responses.BadRequest(err)
+		}
+		request.Query = &search
+	}
+	switch request.Sort {
+	case "":
+		request.Sort = models.SortNewest
+		if request.Query != nil {
+			request.Sort = models.SortRelevance
+		}
+	case models.SortNewest, models.SortOldest, models.SortUpdated:
+	case models.SortRelevance:
+		if request.Query == nil {
+			return request, responses.BadRequest(fmt.Errorf("sort %s requires q", models.SortRelevance))
+		}
+	default:
+		return request, responses.BadRequest(fmt.Errorf(
+			"sort must be one of %s, %s, %s or %s",
+			models.SortNewest, models.SortOldest, models.SortUpdated, models.SortRelevance,
+		))
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return request, responses.BadRequest(errors.New("limit must be a positive integer"))
+		}
+		if value > models.MaxPageSize {
+			value = models.MaxPageSize
+		}
+		request.Limit = value
+	}
+	if encoded := query.Get("cursor"); encoded != "" {
+		cursor, err := models.DecodeCursor(encoded, request.Sort)
+		if err != nil {
+			return request, responses.BadRequest(err)
+		}
+		request.Cursor = &cursor
+	}
+	for _, name := range query["tag"] {
+		err := models.Tag{Name: name}.Validate()
+		if err != nil {
+			return request, responses.BadRequest(fmt.Errorf("tag %q: %w", name, err))
+		}
+	}
+	request.Tags = models.NormalizeTags(query["tag"])
+	request.TagMode = query.Get("tag_mode")
+	switch request.TagMode {
+	case "":
+		request.TagMode = models.TagModeAll
+	case models.TagModeAll, models.TagModeAny:
+	default:
+		return request, responses.BadRequest(fmt.Errorf("tag_mode must be %s or %s", models.TagModeAll, models.TagModeAny))
+	}
+	for _, difficulty := range query["difficulty"] {
+		if !models.IsDifficulty(difficulty) {
+			return request, responses.BadRequest(fmt.Errorf(
+				"difficulty must be one of %s, %s or %s",
+				models.DifficultyEasy, models.DifficultyMedium, models.DifficultyHard,
+			))
+		}
+		request.Difficulties = append(request.Difficulties, difficulty)
+	}
+	for _, bound := range []struct {
+		key   string
+		value *int
+	}{{"min_points", &request.MinPoints}, {"max_points", &request.MaxPoints}, {"max_time_limit", &request.MaxTimeLimit}} {
+		if query.Get(bound.key) == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(query.Get(bound.key))
+		if err != nil || parsed < 1 {
+			return request, responses.BadRequest(fmt.Errorf("%s must be a positive integer", bound.key))
+		}
+		*bound.value = parsed
+	}
+	if since := query.Get("updated_since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return request, responses.BadRequest(errors.New("updated_since must be an RFC 3339 timestamp"))
+		}
+		request.UpdatedSince = parsed
+	}
+	return request, nil
+}
+
+// setLinkHeader sets the Link header to the next and previous page of page
+// Links keep the filters of the request
+func setLinkHeader(w http.ResponseWriter, r *http.Request, request models.PageRequest, page models.QuestionPage) {
+	var links []string
+	for _, link := range []struct{ cursor, rel string }{{page.NextCursor, "next"}, {page.PrevCursor, "prev"}} {
+		if link.cursor == "" {
+			continue
+		}
+		query := r.URL.Query()
+		query.Set("sort", request.Sort)
+		query.Set("limit", strconv.Itoa(request.Limit))
+		query.Set("cursor", link.cursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, query.Encode(), link.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
 // ListQuestions is the handler for GET /questions
+// Pages are selected with the query parameters sort, limit and cursor, the Link header points to the adjacent pages
+// q searches question and option bodies, tag filters by tags and tag_mode selects if all or any of them are required
+// difficulty, min_points, max_points and max_time_limit filter by the scoring metadata,
+// updated_since by the last change
 func (a *App) ListQuestions(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	lastID, _ := strconv.Atoi(r.URL.Query().Get("last_id"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	questions := a.Storage.List(userID, lastID, limit)
-	addJSONPayload(w, http.StatusOK, questions)
+	request, err := parsePageRequest(r)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	page, err := a.Storage.List(userID, request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	setLinkHeader(w, r, request, page)
+	responses.JSON(w, http.StatusOK, page)
+}
+
+// ExportQuestions is the handler for GET /questions/export
+// It returns every question matching the filters of ListQuestions with all their metadata, limit and cursor are ignored
+func (a *App) ExportQuestions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	request, err := parsePageRequest(r)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	request.Limit, request.Cursor = models.MaxPageSize, nil
+	export := models.QuestionExport{ExportedAt: time.Now().UTC(), Questions: []models.Question{}}
+	for {
+		page, err := a.Storage.List(userID, request)
+		if err != nil {
+			responses.Error(w, r, err)
+			return
+		}
+		export.Questions = append(export.Questions, page.Questions...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor, err := models.DecodeCursor(page.NextCursor, request.Sort)
+		if err != nil {
+			responses.Error(w, r, err)
+			return
+		}
+		request.Cursor = &cursor
+	}
+	export.Total = len(export.Questions)
+	w.Header().Set("Content-Disposition", `attachment; filename="questions.json"`)
+	responses.JSON(w, http.StatusOK, export)
 }
 
 // GetQuestion is the handler for GET /questions/{id}
 func (a *App) GetQuestion(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	id, err := parseVarFromRequest(w, r, "id")
+	id, err := parseVarFromRequest(r, "id")
 	if err != nil {
+		responses.Error(w, r, err)
 		return
 	}
 	question, err := a.Storage.Get(id, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		responses.Error(w, r, err)
 		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	writeQuestion(w, question)
 }
 
 // UpdateQuestion is the handler for PUT /questions/{id}
 func (a *App) UpdateQuestion(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	id, err := parseVarFromRequest(w, r, "id")
+	id, err := parseVarFromRequest(r, "id")
 	if err != nil {
+		responses.Error(w, r, err)
 		return
 	}
 	var question models.Question
-	err = json.NewDecoder(r.Body).Decode(&question)
+	err = decodeJSONBody(r, &question)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	question.Version, err = a.ifMatch(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
 	question, err = a.Storage.Update(id, userID, question)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responses.Error(w, r, err)
 		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	writeQuestion(w, question)
+}
+
+// PatchQuestion is the handler for PATCH /questions/{id}
+// The body is a JSON Merge Patch that is applied to the current question
+func (a *App) PatchQuestion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.Error(w, r, responses.BadRequest(err))
+		return
+	}
+	var question models.Question
+	err = a.conditionally(r, id, userID, func(tx storage.Storage) error {
+		current, err := tx.Get(id, userID)
+		if err != nil {
+			return err
+		}
+		patched, err := models.ApplyMergePatch(current, patch)
+		if err != nil {
+			return responses.BadRequest(err)
+		}
+		patched.Version = current.Version
+		question, err = tx.Update(id, userID, patched)
+		return err
+	})
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	writeQuestion(w, question)
 }
 
 // NewQuestion is the handler for POST /questions
 func (a *App) NewQuestion(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
 	var question models.Question
-	err := json.NewDecoder(r.Body).Decode(&question)
+	err := decodeJSONBody(r, &question)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
 	question, err = a.Storage.Add(userID, question)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responses.Error(w, r, err)
 		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	writeQuestion(w, question)
 }
 
 // DeleteQuestion is the handler for DELETE /questions/{id}
 func (a *App) DeleteQuestion(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	id, err := parseVarFromRequest(w, r, "id")
+	id, err := parseVarFromRequest(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	err = a.Storage.Delete(id, userID)
+	err = a.conditionally(r, id, userID, func(tx storage.Storage) error {
+		return tx.Delete(id, userID)
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		responses.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTrash is the handler for GET /questions/trash
+// It returns the deleted questions of the user, the most recently deleted first
+func (a *App) ListTrash(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	questions, err := a.Storage.ListTrash(userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, questions)
+}
+
+// RestoreQuestion is the handler for POST /questions/{id}/restore
+// It moves a question out of the trash
+func (a *App) RestoreQuestion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	question, err := a.Storage.RestoreQuestion(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	writeQuestion(w, question)
+}
+
+// CheckAnswer is the handler for POST /questions/{id}/check
+// It grades the response in the payload and returns the result together with the answer key
+func (a *App) CheckAnswer(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	var response models.CheckRequest
+	err = decodeJSONBody(r, &response)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	question, err := a.Storage.Get(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	result, err := question.Check(response)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, result)
+}
+
+// ListRevisions is the handler for GET /questions/{id}/revisions
+// It returns every revision of the question, oldest first
+func (a *App) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	revisions, err := a.Storage.ListRevisions(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, revisions)
+}
+
+// GetRevision is the handler for GET /questions/{id}/revisions/{number}
+func (a *App) GetRevision(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	number, err := parseVarFromRequest(r, "number")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	revision, err := a.Storage.GetRevision(id, number, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, revision)
+}
+
+// DiffRevisions is the handler for GET /questions/{id}/revisions/diff?from={number}&to={number}
+// It returns every field that changed between the two revisions
+func (a *App) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	revisions := make([]models.Revision, 2)
+	for i, key := range []string{"from", "to"} {
+		number, err := strconv.Atoi(r.URL.Query().Get(key))
+		if err != nil || number < 1 {
+			responses.Error(w, r, responses.BadRequest(fmt.Errorf("%s must be a revision number", key)))
+			return
+		}
+		revisions[i], err = a.Storage.GetRevision(id, number, userID)
+		if err != nil {
+			responses.Error(w, r, err)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, revisions[0].Diff(revisions[1]))
+}
+
+// RestoreRevision is the handler for POST /questions/{id}/revisions/{number}/restore
+// The question is replaced with the snapshot of the revision, which is stored as a new revision
+func (a *App) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	number, err := parseVarFromRequest(r, "number")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	question, err := a.Storage.RestoreRevision(id, number, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	writeQuestion(w, question)
+}
+
+// ListShares is the handler for GET /questions/{id}/shares
+// It returns the users the question is shared with and their roles
+func (a *App) ListShares(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	shares, err := a.Storage.ListShares(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, shares)
+}
+
+// ShareQuestion is the handler for POST /questions/{id}/shares
+// It grants a user a role on the question or changes the role they have
+func (a *App) ShareQuestion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	var request models.ShareRequest
+	err = decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	share, err := a.Storage.ShareQuestion(id, userID, request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, share)
+}
+
+// RevokeShare is the handler for DELETE /questions/{id}/shares/{userID}
+func (a *App) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	sharedUserID, err := parseVarFromRequest(r, "userID")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	err = a.Storage.RevokeShare(id, sharedUserID, userID)
+	if err != nil {
+		responses.Error(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -133,133 +685,681 @@ func (a *App) DeleteQuestion(w http.ResponseWriter, r *http.Request) {
 // AddOption is the handler for POST /questions/{id}/options
 func (a *App) AddOption(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	questionID, err := parseVarFromRequest(w, r, "id")
+	questionID, err := parseVarFromRequest(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
 	var option models.Option
-	err = json.NewDecoder(r.Body).Decode(&option)
+	err = decodeJSONBody(r, &option)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	question, err := a.Storage.AddOption(option, questionID, userID)
+	var question models.Question
+	err = a.conditionally(r, questionID, userID, func(tx storage.Storage) error {
+		question, err = tx.AddOption(option, questionID, userID)
+		return err
+	})
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		responses.Error(w, r, err)
+		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	writeQuestion(w, question)
 }
 
 // UpdateOption is the handler for PUT /questions/{id}/options/{id}
 func (a *App) UpdateOption(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	questionID, err := parseVarFromRequest(w, r, "id")
+	questionID, err := parseVarFromRequest(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	optionID, err := parseVarFromRequest(w, r, "optionID")
+	optionID, err := parseVarFromRequest(r, "optionID")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
 	var option models.Option
-	err = json.NewDecoder(r.Body).Decode(&option)
+	err = decodeJSONBody(r, &option)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	question, err := a.Storage.UpdateOption(option, optionID, questionID, userID)
+	var question models.Question
+	err = a.conditionally(r, questionID, userID, func(tx storage.Storage) error {
+		question, err = tx.UpdateOption(option, optionID, questionID, userID)
+		return err
+	})
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		responses.Error(w, r, err)
+		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	writeQuestion(w, question)
 }
 
 // DeleteOption is the handler for DELETE /questions/{id}/options/{id}
 func (a *App) DeleteOption(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(models.ContextUserID).(int)
-	questionID, err := parseVarFromRequest(w, r, "id")
+	questionID, err := parseVarFromRequest(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	optionID, err := parseVarFromRequest(w, r, "optionID")
+	optionID, err := parseVarFromRequest(r, "optionID")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	var option models.Option
-	err = json.NewDecoder(r.Body).Decode(&option)
+	var question models.Question
+	err = a.conditionally(r, questionID, userID, func(tx storage.Storage) error {
+		question, err = tx.DeleteOption(optionID, questionID, userID)
+		return err
+	})
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	writeQuestion(w, question)
+}
+
+// ReorderOptions is the handler for POST /questions/{id}/options/reorder
+func (a *App) ReorderOptions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	questionID, err := parseVarFromRequest(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	question, err := a.Storage.DeleteOption(optionID, questionID, userID)
+	var request models.ReorderRequest
+	err = decodeJSONBody(r, &request)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		responses.Error(w, r, err)
+		return
 	}
-	addJSONPayload(w, http.StatusOK, question)
+	var question models.Question
+	err = a.conditionally(r, questionID, userID, func(tx storage.Storage) error {
+		question, err = tx.ReorderOptions(request.OptionIDs, questionID, userID)
+		return err
+	})
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	writeQuestion(w, question)
+}
+
+// ListTags is the handler for GET /tags
+func (a *App) ListTags(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	tags, err := a.Storage.ListTags(userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tags)
+}
+
+// GetTag is the handler for GET /tags/{id}
+func (a *App) GetTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	tag, err := a.Storage.GetTag(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tag)
+}
+
+// NewTag is the handler for POST /tags
+func (a *App) NewTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	var tag models.Tag
+	err := decodeJSONBody(r, &tag)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	tag, err = a.Storage.AddTag(userID, tag)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tag)
+}
+
+// UpdateTag is the handler for PUT /tags/{id}
+func (a *App) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	var tag models.Tag
+	err = decodeJSONBody(r, &tag)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	tag, err = a.Storage.UpdateTag(id, userID, tag)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tag)
+}
+
+// DeleteTag is the handler for DELETE /tags/{id}
+func (a *App) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	err = a.Storage.DeleteTag(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTests is the handler for GET /tests
+func (a *App) ListTests(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	tests, err := a.Storage.ListTests(userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tests)
+}
+
+// GetTest is the handler for GET /tests/{id}
+func (a *App) GetTest(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	test, err := a.Storage.GetTest(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, test)
+}
+
+// NewTest is the handler for POST /tests
+func (a *App) NewTest(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	var test models.Test
+	err := decodeJSONBody(r, &test)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	test, err = a.Storage.AddTest(userID, test)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, test)
+}
+
+// UpdateTest is the handler for PUT /tests/{id}
+func (a *App) UpdateTest(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	var test models.Test
+	err = decodeJSONBody(r, &test)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	test, err = a.Storage.UpdateTest(id, userID, test)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, test)
+}
+
+// DeleteTest is the handler for DELETE /tests/{id}
+func (a *App) DeleteTest(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	err = a.Storage.DeleteTest(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddTestQuestions is the handler for POST /tests/{id}/questions
+func (a *App) AddTestQuestions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	var request models.TestQuestionsRequest
+	err = decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	test, err := a.Storage.AddTestQuestions(id, userID, request.QuestionIDs)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, test)
+}
+
+// RemoveTestQuestion is the handler for DELETE /tests/{id}/questions/{questionID}
+func (a *App) RemoveTestQuestion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	questionID, err := parseVarFromRequest(r, "questionID")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	test, err := a.Storage.RemoveTestQuestion(id, questionID, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, test)
+}
+
+// NewDeliveryToken is the handler for POST /delivery/tokens
+// It issues a delivery token for questions of the user, expires_in is capped at DELIVERY_TOKEN_TTL
+func (a *App) NewDeliveryToken(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	var request models.DeliveryTokenRequest
+	err := decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	err = request.Validate()
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	scope := models.DeliveryScope{AuthorID: userID}
+	for _, id := range request.QuestionIDs {
+		if scope.Allows(id) {
+			continue
+		}
+		_, err = a.Storage.Get(id, userID)
+		if err != nil {
+			responses.Error(w, r, err)
+			return
+		}
+		scope.QuestionIDs = append(scope.QuestionIDs, id)
+	}
+	if request.Seed != nil {
+		scope.Seed = *request.Seed
+	} else if scope.Seed, err = storage.RandomSeed(); err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	ttl := time.Duration(request.ExpiresIn) * time.Second
+	if ttl == 0 || ttl > a.Tokens.DeliveryTTL {
+		ttl = a.Tokens.DeliveryTTL
+	}
+	token, err := storage.NewDeliveryToken(scope, ttl, a.Tokens)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, token)
+}
+
+// ListDeliveredQuestions is the handler for GET /delivery/questions
+// It returns every question of the delivery token in order, without answer keys
+func (a *App) ListDeliveredQuestions(w http.ResponseWriter, r *http.Request) {
+	scope := r.Context().Value(models.ContextDeliveryScope).(models.DeliveryScope)
+	questions := make([]models.DeliveredQuestion, 0, len(scope.QuestionIDs))
+	for _, id := range scope.QuestionIDs {
+		question, err := a.Storage.Get(id, scope.AuthorID)
+		if err != nil {
+			responses.Error(w, r, err)
+			return
+		}
+		questions = append(questions, question.Deliver(scope.Seed))
+	}
+	responses.JSON(w, http.StatusOK, questions)
+}
+
+// GetDeliveredQuestion is the handler for GET /delivery/questions/{id}
+// Only questions of the delivery token can be read, they never contain answer keys
+func (a *App) GetDeliveredQuestion(w http.ResponseWriter, r *http.Request) {
+	scope := r.Context().Value(models.ContextDeliveryScope).(models.DeliveryScope)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	if !scope.Allows(id) {
+		responses.Error(w, r, &responses.APIError{
+			Status:  http.StatusForbidden,
+			Code:    responses.CodeForbidden,
+			Message: fmt.Sprintf("question %d is not part of this delivery", id),
+		})
+		return
+	}
+	question, err := a.Storage.Get(id, scope.AuthorID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, question.Deliver(scope.Seed))
+}
+
+// StartAttempt is the handler for POST /attempts
+// It starts an attempt for a test or questions of the user and issues the token for its candidate
+func (a *App) StartAttempt(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	var request models.StartAttemptRequest
+	err := decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	attempt, err := a.Storage.StartAttempt(userID, request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	token, err := storage.NewAttemptToken(attempt, a.Tokens)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, token)
+}
+
+// ListAttempts is the handler for GET /attempts
+func (a *App) ListAttempts(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	attempts, err := a.Storage.ListAttempts(userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, attempts)
+}
+
+// GetAttempt is the handler for GET /attempts/{id}
+// It returns the attempt with every graded answer and its score
+func (a *App) GetAttempt(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	id, err := parseVarFromRequest(r, "id")
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	attempt, err := a.Storage.GetAttempt(id, userID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, attempt)
+}
+
+// GetAttemptProgress is the handler for GET /attempts/current
+// It returns the progress of the attempt token, candidates never see results
+func (a *App) GetAttemptProgress(w http.ResponseWriter, r *http.Request) {
+	attemptID := r.Context().Value(models.ContextAttemptID).(int)
+	attempt, err := a.Storage.CandidateAttempt(attemptID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, attempt.Progress())
+}
+
+// GetAttemptQuestion is the handler for GET /attempts/current/question
+// It returns the current question of the attempt token without its answer key
+func (a *App) GetAttemptQuestion(w http.ResponseWriter, r *http.Request) {
+	attemptID := r.Context().Value(models.ContextAttemptID).(int)
+	attempt, err := a.Storage.CandidateAttempt(attemptID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	if attempt.Status != models.AttemptInProgress {
+		responses.Error(w, r, fmt.Errorf("%w: attempt %d", storage.ErrAttemptClosed, attemptID))
+		return
+	}
+	question, err := attempt.CurrentQuestion()
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, question.Deliver(int64(attempt.ID)))
+}
+
+// AnswerAttempt is the handler for POST /attempts/current/answers
+// It records the answer to the current question of the attempt token and returns the progress
+func (a *App) AnswerAttempt(w http.ResponseWriter, r *http.Request) {
+	attemptID := r.Context().Value(models.ContextAttemptID).(int)
+	var request models.AnswerRequest
+	err := decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	attempt, err := a.Storage.AnswerAttempt(attemptID, request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, attempt.Progress())
+}
+
+// SubmitAttempt is the handler for POST /attempts/current/submit
+// It closes the attempt of the attempt token, unanswered questions get no points
+func (a *App) SubmitAttempt(w http.ResponseWriter, r *http.Request) {
+	attemptID := r.Context().Value(models.ContextAttemptID).(int)
+	attempt, err := a.Storage.SubmitAttempt(attemptID)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, attempt.Progress())
 }
 
 // CreateUser is the handler for POST /users
 func (a *App) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decodeJSONBody(r, &user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
 	response, err := a.Storage.CreateUser(user.Username, user.Password)
 	if err != nil {
-		http.Error(w, "username already in use", http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	addJSONPayload(w, http.StatusOK, response)
+	responses.JSON(w, http.StatusOK, response)
 }
 
 // CreateToken is the handler for POST /users/token
 func (a *App) CreateToken(w http.ResponseWriter, r *http.Request) {
 	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decodeJSONBody(r, &user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	token, err := a.Storage.CreateToken(user.Username, user.Password, a.JWTSecret)
+	token, err := a.Storage.CreateToken(user.Username, user.Password, a.Tokens)
 	if err != nil {
-		http.Error(w, "user does not exist or wrong password", http.StatusBadRequest)
+		responses.Error(w, r, err)
 		return
 	}
-	addJSONPayload(w, http.StatusOK, token)
+	responses.JSON(w, http.StatusOK, token)
 }
 
-func main() {
-	app := App{}
-	app.Initialize()
-	jwtMiddleware := middlewares.JWTMiddleware{Secret: app.JWTSecret, Storage: app.Storage}
+// RefreshToken is the handler for POST /users/token/refresh
+func (a *App) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var request models.RefreshTokenRequest
+	err := decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	token, err := a.Storage.RefreshToken(request.RefreshToken, a.Tokens)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, token)
+}
+
+// Logout is the handler for POST /users/logout
+// It revokes the refresh token from the payload and the access token used for the request
+func (a *App) Logout(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(models.ContextUserID).(int)
+	jti := r.Context().Value(models.ContextTokenID).(string)
+	expiresAt := r.Context().Value(models.ContextTokenExpiry).(time.Time)
+	var request models.RefreshTokenRequest
+	err := decodeJSONBody(r, &request)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	err = a.Storage.RevokeTokens(userID, request.RefreshToken, jti, expiresAt)
+	if err != nil {
+		responses.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Router returns the router with all routes and middlewares of the app
+func (a *App) Router() *mux.Router {
+	jwtMiddleware := middlewares.JWTMiddleware{Secret: a.Tokens.Secret, Issuer: a.Tokens.Issuer, Storage: a.Storage}
 	router := mux.NewRouter()
+	router.Use(middlewares.RequestIDMiddleware)
 	router.Use(middlewares.LoggingMiddleware)
 
 	questions := router.PathPrefix("/questions").Subrouter()
 	questions.Use(jwtMiddleware.Middleware)
-	questions.HandleFunc("", app.ListQuestions).Methods("GET")
-	questions.HandleFunc("", app.NewQuestion).Methods("POST")
-	questions.HandleFunc("/{id}", app.GetQuestion).Methods("GET")
-	questions.HandleFunc("/{id}", app.UpdateQuestion).Methods("PUT")
-	questions.HandleFunc("/{id}", app.DeleteQuestion).Methods("DELETE")
-	questions.HandleFunc("/{id}/options", app.AddOption).Methods("POST")
-	questions.HandleFunc("/{id}/options/{optionID}", app.UpdateOption).Methods("PUT")
-	questions.HandleFunc("/{id}/options/{optionID}", app.DeleteOption).Methods("DELETE")
+	questions.HandleFunc("", a.ListQuestions).Methods("GET")
+	questions.HandleFunc("", a.NewQuestion).Methods("POST")
+	questions.HandleFunc("/trash", a.ListTrash).Methods("GET")
+	questions.HandleFunc("/export", a.ExportQuestions).Methods("GET")
+	questions.HandleFunc("/{id}", a.GetQuestion).Methods("GET")
+	questions.HandleFunc("/{id}", a.UpdateQuestion).Methods("PUT")
+	questions.HandleFunc("/{id}", a.PatchQuestion).Methods("PATCH")
+	questions.HandleFunc("/{id}", a.DeleteQuestion).Methods("DELETE")
+	questions.HandleFunc("/{id}/restore", a.RestoreQuestion).Methods("POST")
+	questions.HandleFunc("/{id}/check", a.CheckAnswer).Methods("POST")
+	questions.HandleFunc("/{id}/revisions", a.ListRevisions).Methods("GET")
+	questions.HandleFunc("/{id}/revisions/diff", a.DiffRevisions).Methods("GET")
+	questions.HandleFunc("/{id}/revisions/{number}", a.GetRevision).Methods("GET")
+	questions.HandleFunc("/{id}/revisions/{number}/restore", a.RestoreRevision).Methods("POST")
+	questions.HandleFunc("/{id}/shares", a.ListShares).Methods("GET")
+	questions.HandleFunc("/{id}/shares", a.ShareQuestion).Methods("POST")
+	questions.HandleFunc("/{id}/shares/{userID}", a.RevokeShare).Methods("DELETE")
+	questions.HandleFunc("/{id}/options", a.AddOption).Methods("POST")
+	questions.HandleFunc("/{id}/options/reorder", a.ReorderOptions).Methods("POST")
+	questions.HandleFunc("/{id}/options/{optionID}", a.UpdateOption).Methods("PUT")
+	questions.HandleFunc("/{id}/options/{optionID}", a.DeleteOption).Methods("DELETE")
+
+	tags := router.PathPrefix("/tags").Subrouter()
+	tags.Use(jwtMiddleware.Middleware)
+	tags.HandleFunc("", a.ListTags).Methods("GET")
+	tags.HandleFunc("", a.NewTag).Methods("POST")
+	tags.HandleFunc("/{id}", a.GetTag).Methods("GET")
+	tags.HandleFunc("/{id}", a.UpdateTag).Methods("PUT")
+	tags.HandleFunc("/{id}", a.DeleteTag).Methods("DELETE")
+
+	tests := router.PathPrefix("/tests").Subrouter()
+	tests.Use(jwtMiddleware.Middleware)
+	tests.HandleFunc("", a.ListTests).Methods("GET")
+	tests.HandleFunc("", a.NewTest).Methods("POST")
+	tests.HandleFunc("/{id}", a.GetTest).Methods("GET")
+	tests.HandleFunc("/{id}", a.UpdateTest).Methods("PUT")
+	tests.HandleFunc("/{id}", a.DeleteTest).Methods("DELETE")
+	tests.HandleFunc("/{id}/questions", a.AddTestQuestions).Methods("POST")
+	tests.HandleFunc("/{id}/questions/{questionID}", a.RemoveTestQuestion).Methods("DELETE")
+
+	deliveryMiddleware := middlewares.DeliveryMiddleware{Secret: a.Tokens.Secret, Issuer: a.Tokens.Issuer}
+	delivery := router.PathPrefix("/delivery").Subrouter()
+	delivery.Handle("/tokens", jwtMiddleware.Middleware(http.HandlerFunc(a.NewDeliveryToken))).Methods("POST")
+	delivery.Handle("/questions", deliveryMiddleware.Middleware(http.HandlerFunc(a.ListDeliveredQuestions))).Methods("GET")
+	delivery.Handle("/questions/{id}", deliveryMiddleware.Middleware(http.HandlerFunc(a.GetDeliveredQuestion))).Methods("GET")
+
+	attemptMiddleware := middlewares.AttemptMiddleware{Secret: a.Tokens.Secret, Issuer: a.Tokens.Issuer}
+	attempts := router.PathPrefix("/attempts").Subrouter()
+	attempts.Handle("", jwtMiddleware.Middleware(http.HandlerFunc(a.ListAttempts))).Methods("GET")
+	attempts.Handle("", jwtMiddleware.Middleware(http.HandlerFunc(a.StartAttempt))).Methods("POST")
+	attempts.Handle("/current", attemptMiddleware.Middleware(http.HandlerFunc(a.GetAttemptProgress))).Methods("GET")
+	attempts.Handle("/current/question", attemptMiddleware.Middleware(http.HandlerFunc(a.GetAttemptQuestion))).Methods("GET")
+	attempts.Handle("/current/answers", attemptMiddleware.Middleware(http.HandlerFunc(a.AnswerAttempt))).Methods("POST")
+	attempts.Handle("/current/submit", attemptMiddleware.Middleware(http.HandlerFunc(a.SubmitAttempt))).Methods("POST")
+	attempts.Handle("/{id}", jwtMiddleware.Middleware(http.HandlerFunc(a.GetAttempt))).Methods("GET")
 
 	users := router.PathPrefix("/users").Subrouter()
-	users.HandleFunc("", app.CreateUser).Methods("POST")
-	users.HandleFunc("/token", app.CreateToken).Methods("POST")
+	users.HandleFunc("", a.CreateUser).Methods("POST")
+	users.HandleFunc("/token", a.CreateToken).Methods("POST")
+	users.HandleFunc("/token/refresh", a.RefreshToken).Methods("POST")
+	users.Handle("/logout", jwtMiddleware.Middleware(http.HandlerFunc(a.Logout))).Methods("POST")
+	return router
+}
 
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrator, err := newMigrator()
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = runMigrate(migrator, os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	purgeInterval := getEnvDuration("TRASH_PURGE_INTERVAL", time.Hour)
+	if purgeInterval <= 0 {
+		log.Fatalf("TRASH_PURGE_INTERVAL must be positive, got %v", purgeInterval)
+	}
+	app := App{}
+	app.Initialize()
+	go app.purgeTrash(purgeInterval)
 	server := &http.Server{
 		Addr:         getEnv("HOST", "127.0.0.1") + ":" + getEnv("PORT", "3000"),
-		Handler:      router,
+		Handler:      app.Router(),
 		ReadTimeout:  1 * time.Second,
 		WriteTimeout: 1 * time.Second,
 	}
@@ -0,0 +1,613 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/storage"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp returns the router of an App backed by MemoryStorage and an access token of a new user
+func newTestApp(t *testing.T) (http.Handler, string) {
+	t.Helper()
+	return newConfiguredTestApp(t, App{})
+}
+
+// newConfiguredTestApp is newTestApp for app, only its storage and token config are replaced
+func newConfiguredTestApp(t *testing.T, app App) (http.Handler, string) {
+	t.Helper()
+	app.Storage = storage.NewMemoryStorage(storage.NewPasswordHasher(bcrypt.MinCost))
+	app.Tokens = storage.TokenConfig{
+		Secret:      []byte("test-secret"),
+		Issuer:      "test",
+		AccessTTL:   time.Minute,
+		RefreshTTL:  time.Hour,
+		DeliveryTTL: time.Hour,
+	}
+	router := app.Router()
+	user := models.User{Username: "alice", Password: "password"}
+	request(t, router, "", "POST", "/users", user, nil, http.StatusOK)
+	var token models.JWTTokenResponse
+	request(t, router, "", "POST", "/users/token", user, &token, http.StatusOK)
+	return router, token.Token
+}
+
+// request sends body as JSON to handler, checks the status code and decodes the response into response if not nil
+func request(t *testing.T, handler http.Handler, token, method, path string, body, response interface{}, status int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != status {
+		t.Fatalf("%s %s: got status %d, want %d: %s", method, path, w.Code, status, w.Body.String())
+	}
+	if response != nil {
+		if err := json.NewDecoder(w.Body).Decode(response); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var sunQuestion = models.Question{
+	Body: "Where does the sun set?",
+	Options: []models.Option{
+		{Body: "East"},
+		{Body: "West", Correct: true},
+	},
+}
+
+func TestQuestionLifecycle(t *testing.T) {
+	router, token := newTestApp(t)
+	var created models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &created, http.StatusOK)
+	if created.ID == 0 || len(created.Options) != 2 {
+		t.Fatalf("got %+v", created)
+	}
+
+	var page models.QuestionPage
+	request(t, router, token, "GET", "/questions", nil, &page, http.StatusOK)
+	if len(page.Questions) != 1 || page.Questions[0].ID != created.ID || page.Limit != models.DefaultPageSize {
+		t.Fatalf("got %+v", page)
+	}
+
+	path := "/questions/" + strconv.Itoa(created.ID)
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusNoContent)
+	request(t, router, token, "GET", path, nil, nil, http.StatusNotFound)
+}
+
+func TestUnauthorized(t *testing.T) {
+	router, _ := newTestApp(t)
+	var response models.ErrorResponse
+	request(t, router, "", "GET", "/questions", nil, &response, http.StatusUnauthorized)
+	if response.Code == "" {
+		t.Fatalf("got %+v, want error code", response)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	router, _ := newTestApp(t)
+	user := models.User{Username: "alice", Password: "password"}
+	var token models.JWTTokenResponse
+	request(t, router, "", "POST", "/users/token", user, &token, http.StatusOK)
+	logout := models.RefreshTokenRequest{RefreshToken: token.RefreshToken}
+	request(t, router, "", "POST", "/users/logout", logout, nil, http.StatusUnauthorized)
+	request(t, router, token.Token, "POST", "/users/logout", logout, nil, http.StatusNoContent)
+
+	// both the access and the refresh token are revoked
+	request(t, router, token.Token, "GET", "/questions", nil, nil, http.StatusUnauthorized)
+	request(t, router, "", "POST", "/users/token/refresh", logout, nil, http.StatusUnauthorized)
+
+	// other sessions are still valid
+	request(t, router, "", "POST", "/users/token", user, &token, http.StatusOK)
+	request(t, router, token.Token, "GET", "/questions", nil, nil, http.StatusOK)
+}
+
+func TestInvalidQuestion(t *testing.T) {
+	router, token := newTestApp(t)
+	var response models.ErrorResponse
+	request(t, router, token, "POST", "/questions", models.Question{Body: "No options"}, &response, http.StatusUnprocessableEntity)
+	if response.Details == nil {
+		t.Fatalf("got %+v, want validation details", response)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	router, token := newTestApp(t)
+	for i := 0; i < 3; i++ {
+		request(t, router, token, "POST", "/questions", sunQuestion, nil, http.StatusOK)
+	}
+	r := httptest.NewRequest("GET", "/questions?limit=2&sort=oldest", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	var page models.QuestionPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Questions) != 2 || page.Total != 3 || page.NextCursor == "" {
+		t.Fatalf("got %+v", page)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, page.NextCursor) || !strings.Contains(link, `rel="next"`) {
+		t.Fatalf("got Link header %q", link)
+	}
+
+	request(t, router, token, "GET", "/questions?limit=0", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?sort=random", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?sort=newest&cursor="+page.NextCursor, nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?difficulty=impossible", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?min_points=none", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?updated_since=yesterday", nil, nil, http.StatusBadRequest)
+
+	since := url.QueryEscape(page.Questions[0].UpdatedAt.Add(time.Hour).Format(time.RFC3339))
+	request(t, router, token, "GET", "/questions?updated_since="+since, nil, &page, http.StatusOK)
+	if page.Total != 0 {
+		t.Fatalf("got %+v", page)
+	}
+}
+
+func TestSearchQuestions(t *testing.T) {
+	router, token := newTestApp(t)
+	request(t, router, token, "POST", "/questions", sunQuestion, nil, http.StatusOK)
+	var page models.QuestionPage
+	request(t, router, token, "GET", "/questions?q=%22sun+se*%22", nil, &page, http.StatusOK)
+	if len(page.Questions) != 1 || page.Questions[0].Snippet == "" {
+		t.Fatalf("got %+v", page)
+	}
+	request(t, router, token, "GET", "/questions?q=moon", nil, &page, http.StatusOK)
+	if len(page.Questions) != 0 {
+		t.Fatalf("got %+v", page)
+	}
+	request(t, router, token, "GET", "/questions?q=***", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?sort=relevance", nil, nil, http.StatusBadRequest)
+}
+
+func TestExportQuestions(t *testing.T) {
+	router, token := newTestApp(t)
+	for i := 0; i < models.MaxPageSize; i++ {
+		request(t, router, token, "POST", "/questions", sunQuestion, nil, http.StatusOK)
+	}
+	hard := sunQuestion
+	hard.Difficulty, hard.TimeLimit, hard.Points, hard.Explanation = models.DifficultyHard, 90, 5, "The earth rotates eastward."
+	request(t, router, token, "POST", "/questions", hard, nil, http.StatusOK)
+
+	r := httptest.NewRequest("GET", "/questions/export?limit=1", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	var export models.QuestionExport
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || export.Total != models.MaxPageSize+1 || len(export.Questions) != export.Total {
+		t.Fatalf("got status %d and %d of %d questions", w.Code, len(export.Questions), export.Total)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "attachment") {
+		t.Fatalf("got Content-Disposition %q", disposition)
+	}
+
+	request(t, router, token, "GET", "/questions/export?difficulty=hard", nil, &export, http.StatusOK)
+	if export.Total != 1 {
+		t.Fatalf("got %+v", export)
+	}
+	exported := export.Questions[0]
+	if exported.Difficulty != hard.Difficulty || exported.TimeLimit != 90 || exported.Points != 5 || exported.Explanation != hard.Explanation {
+		t.Fatalf("got %+v", exported)
+	}
+	request(t, router, token, "GET", "/questions/export?difficulty=impossible", nil, nil, http.StatusBadRequest)
+}
+
+func TestTags(t *testing.T) {
+	router, token := newTestApp(t)
+	tagged := sunQuestion
+	tagged.Tags = []string{"Geography", "sun"}
+	request(t, router, token, "POST", "/questions", tagged, nil, http.StatusOK)
+	request(t, router, token, "POST", "/questions", sunQuestion, nil, http.StatusOK)
+
+	var page models.QuestionPage
+	request(t, router, token, "GET", "/questions?tag=geography&tag=SUN", nil, &page, http.StatusOK)
+	if len(page.Questions) != 1 || len(page.Questions[0].Tags) != 2 {
+		t.Fatalf("got %+v", page)
+	}
+	request(t, router, token, "GET", "/questions?tag=geography&tag=moon&tag_mode=any", nil, &page, http.StatusOK)
+	if len(page.Questions) != 1 {
+		t.Fatalf("got %+v", page)
+	}
+	request(t, router, token, "GET", "/questions?tag=geography&tag_mode=none", nil, nil, http.StatusBadRequest)
+	request(t, router, token, "GET", "/questions?tag=", nil, nil, http.StatusBadRequest)
+
+	var tags []models.Tag
+	request(t, router, token, "GET", "/tags", nil, &tags, http.StatusOK)
+	if len(tags) != 2 || tags[0].Name != "geography" || tags[0].QuestionCount != 1 {
+		t.Fatalf("got %+v", tags)
+	}
+	var tag models.Tag
+	request(t, router, token, "POST", "/tags", models.Tag{Name: "Astronomy"}, &tag, http.StatusOK)
+	if tag.ID == 0 || tag.Name != "astronomy" {
+		t.Fatalf("got %+v", tag)
+	}
+	request(t, router, token, "POST", "/tags", models.Tag{Name: "sun"}, nil, http.StatusConflict)
+	path := "/tags/" + strconv.Itoa(tags[1].ID)
+	request(t, router, token, "PUT", path, models.Tag{Name: "star"}, &tag, http.StatusOK)
+	if tag.Name != "star" || tag.QuestionCount != 1 {
+		t.Fatalf("got %+v", tag)
+	}
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusNoContent)
+	request(t, router, token, "GET", path, nil, nil, http.StatusNotFound)
+}
+
+func TestCheckAnswer(t *testing.T) {
+	router, token := newTestApp(t)
+	check := func(question models.Question, response models.CheckRequest) models.CheckResult {
+		t.Helper()
+		var created models.Question
+		request(t, router, token, "POST", "/questions", question, &created, http.StatusOK)
+		for i, id := range response.OptionIDs {
+			// option IDs refer to the option index of question
+			response.OptionIDs[i] = created.Options[id].ID
+		}
+		var result models.CheckResult
+		request(t, router, token, "POST", "/questions/"+strconv.Itoa(created.ID)+"/check", response, &result, http.StatusOK)
+		return result
+	}
+	colors := models.Question{
+		Body:   "Which colors are in the German flag?",
+		Points: 4,
+		Options: []models.Option{
+			{Body: "Black", Correct: true},
+			{Body: "Red", Correct: true},
+			{Body: "Blue"},
+			{Body: "Gold", Correct: true},
+		},
+	}
+	if result := check(colors, models.CheckRequest{OptionIDs: []int{0, 1, 3}}); !result.Correct || result.Points != 4 || len(result.CorrectOptions) != 3 {
+		t.Fatalf("got %+v", result)
+	}
+	if result := check(colors, models.CheckRequest{OptionIDs: []int{0, 1, 2}}); result.Correct || result.Score != 1.0/3 {
+		t.Fatalf("got %+v", result)
+	}
+	if result := check(colors, models.CheckRequest{OptionIDs: []int{2}}); result.Score != 0 {
+		t.Fatalf("got %+v", result)
+	}
+	single := sunQuestion
+	single.Type = models.QuestionTypeSingleChoice
+	if result := check(single, models.CheckRequest{OptionIDs: []int{0, 1}}); result.Correct {
+		t.Fatalf("got %+v", result)
+	}
+	if result := check(single, models.CheckRequest{OptionIDs: []int{1}}); !result.Correct || result.Points != 1 {
+		t.Fatalf("got %+v", result)
+	}
+
+	freeText := models.Question{
+		Body:            "Which keyword starts a goroutine?",
+		Type:            models.QuestionTypeFreeText,
+		AcceptedAnswers: []models.AcceptedAnswer{{Answer: "go"}, {Answer: "go +func", Regex: true}},
+	}
+	for answer, correct := range map[string]bool{" GO ": true, "go   func": true, "goroutine": false, "": false} {
+		if result := check(freeText, models.CheckRequest{Answer: answer}); result.Correct != correct || len(result.AcceptedAnswers) != 2 {
+			t.Fatalf("%q: got %+v", answer, result)
+		}
+	}
+	numeric := models.Question{
+		Body:          "What is pi to two decimals?",
+		Type:          models.QuestionTypeNumeric,
+		NumericAnswer: &models.NumericAnswer{Value: 3.14, Tolerance: 0.005},
+	}
+	for value, correct := range map[float64]bool{3.14: true, 3.144: true, 3.15: false} {
+		value := value
+		if result := check(numeric, models.CheckRequest{Value: &value}); result.Correct != correct {
+			t.Fatalf("%v: got %+v", value, result)
+		}
+	}
+
+	var created models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &created, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(created.ID) + "/check"
+	request(t, router, token, "POST", path, models.CheckRequest{OptionIDs: []int{-1}}, nil, http.StatusUnprocessableEntity)
+	request(t, router, token, "POST", "/questions/0/check", models.CheckRequest{}, nil, http.StatusNotFound)
+}
+
+func TestDelivery(t *testing.T) {
+	router, token := newTestApp(t)
+	shuffled := models.Question{Body: "Which number is prime?", ShuffleOptions: true, Explanation: "7 is only divisible by 1 and 7"}
+	for i := 0; i < 8; i++ {
+		shuffled.Options = append(shuffled.Options, models.Option{Body: strconv.Itoa(i), Correct: i == 7})
+	}
+	var question, other models.Question
+	request(t, router, token, "POST", "/questions", shuffled, &question, http.StatusOK)
+	request(t, router, token, "POST", "/questions", sunQuestion, &other, http.StatusOK)
+
+	seed := int64(1) << 60
+	var delivery models.DeliveryTokenResponse
+	tokenRequest := models.DeliveryTokenRequest{QuestionIDs: []int{question.ID, question.ID}, Seed: &seed}
+	request(t, router, token, "POST", "/delivery/tokens", tokenRequest, &delivery, http.StatusOK)
+	if delivery.Seed != seed || len(delivery.QuestionIDs) != 1 || delivery.ExpiresIn != 3600 {
+		t.Fatalf("got %+v", delivery)
+	}
+
+	path := "/delivery/questions/" + strconv.Itoa(question.ID)
+	r := httptest.NewRequest("GET", path, nil)
+	r.Header.Set("Authorization", "Bearer "+delivery.Token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "correct") || strings.Contains(w.Body.String(), "explanation") {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+	var delivered models.DeliveredQuestion
+	if err := json.NewDecoder(w.Body).Decode(&delivered); err != nil {
+		t.Fatal(err)
+	}
+	want := question.Deliver(seed)
+	inOrder := true
+	for i, option := range delivered.Options {
+		if option.ID != want.Options[i].ID || option.Position != i {
+			t.Fatalf("got options %+v, want %+v", delivered.Options, want.Options)
+		}
+		inOrder = inOrder && option.ID == question.Options[i].ID
+	}
+	if len(delivered.Options) != 8 || inOrder {
+		t.Fatalf("got options %+v, want them shuffled", delivered.Options)
+	}
+	var listed []models.DeliveredQuestion
+	request(t, router, delivery.Token, "GET", "/delivery/questions", nil, &listed, http.StatusOK)
+	if len(listed) != 1 || listed[0].Options[0].ID != delivered.Options[0].ID {
+		t.Fatalf("got %+v", listed)
+	}
+
+	request(t, router, delivery.Token, "GET", "/delivery/questions/"+strconv.Itoa(other.ID), nil, nil, http.StatusForbidden)
+	request(t, router, delivery.Token, "GET", "/questions/"+strconv.Itoa(question.ID), nil, nil, http.StatusUnauthorized)
+	request(t, router, token, "GET", path, nil, nil, http.StatusUnauthorized)
+	request(t, router, token, "POST", "/delivery/tokens", models.DeliveryTokenRequest{}, nil, http.StatusUnprocessableEntity)
+	request(t, router, token, "POST", "/delivery/tokens", models.DeliveryTokenRequest{QuestionIDs: []int{-1}}, nil, http.StatusNotFound)
+}
+
+func TestTests(t *testing.T) {
+	router, token := newTestApp(t)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	var test models.Test
+	request(t, router, token, "POST", "/tests", models.Test{Title: "Geography", PassThreshold: 50}, &test, http.StatusOK)
+	path := "/tests/" + strconv.Itoa(test.ID)
+	request(t, router, token, "POST", path+"/questions", models.TestQuestionsRequest{QuestionIDs: []int{question.ID}}, &test, http.StatusOK)
+	if len(test.QuestionIDs) != 1 || test.QuestionIDs[0] != question.ID {
+		t.Fatalf("got %+v", test)
+	}
+	var tests []models.Test
+	request(t, router, token, "GET", "/tests", nil, &tests, http.StatusOK)
+	if len(tests) != 1 || tests[0].Title != "Geography" {
+		t.Fatalf("got %+v", tests)
+	}
+	request(t, router, token, "DELETE", path+"/questions/"+strconv.Itoa(question.ID), nil, &test, http.StatusOK)
+	if len(test.QuestionIDs) != 0 {
+		t.Fatalf("got %+v", test)
+	}
+	request(t, router, token, "POST", "/tests", models.Test{}, nil, http.StatusUnprocessableEntity)
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusNoContent)
+	request(t, router, token, "GET", path, nil, nil, http.StatusNotFound)
+}
+
+func TestAttempts(t *testing.T) {
+	router, token := newTestApp(t)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	var test models.Test
+	request(t, router, token, "POST", "/tests", models.Test{Title: "Geography", TimeLimit: 600, PassThreshold: 50, QuestionIDs: []int{question.ID}}, &test, http.StatusOK)
+
+	var started models.AttemptTokenResponse
+	request(t, router, token, "POST", "/attempts", models.StartAttemptRequest{TestID: test.ID, Candidate: "carol"}, &started, http.StatusOK)
+	if started.Attempt.ExpiresAt == nil || started.ExpiresIn <= 600 || started.ExpiresIn > 600+5*60 {
+		t.Fatalf("got %+v", started)
+	}
+	candidate := started.Token
+	request(t, router, candidate, "GET", "/questions", nil, nil, http.StatusUnauthorized)
+	request(t, router, token, "GET", "/attempts/current", nil, nil, http.StatusUnauthorized)
+
+	r := httptest.NewRequest("GET", "/attempts/current/question", nil)
+	r.Header.Set("Authorization", "Bearer "+candidate)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "correct") {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+	var correct int
+	for _, option := range question.Options {
+		if option.Correct {
+			correct = option.ID
+		}
+	}
+	answer := models.AnswerRequest{QuestionID: question.ID, CheckRequest: models.CheckRequest{OptionIDs: []int{correct}}}
+	var progress models.AttemptProgress
+	request(t, router, candidate, "POST", "/attempts/current/answers", answer, &progress, http.StatusOK)
+	if progress.Answered != 1 || progress.Total != 1 || progress.Status != models.AttemptInProgress {
+		t.Fatalf("got %+v", progress)
+	}
+	request(t, router, candidate, "GET", "/attempts/current/question", nil, nil, http.StatusConflict)
+	request(t, router, candidate, "POST", "/attempts/current/submit", nil, &progress, http.StatusOK)
+	if progress.Status != models.AttemptSubmitted {
+		t.Fatalf("got %+v", progress)
+	}
+	request(t, router, candidate, "POST", "/attempts/current/answers", answer, nil, http.StatusConflict)
+
+	var attempt models.Attempt
+	request(t, router, token, "GET", "/attempts/"+strconv.Itoa(started.Attempt.ID), nil, &attempt, http.StatusOK)
+	if attempt.Score != 1 || attempt.Percentage != 100 || attempt.Passed == nil || !*attempt.Passed || attempt.Candidate != "carol" {
+		t.Fatalf("got %+v", attempt)
+	}
+	var attempts []models.Attempt
+	request(t, router, token, "GET", "/attempts", nil, &attempts, http.StatusOK)
+	if len(attempts) != 1 {
+		t.Fatalf("got %+v", attempts)
+	}
+	request(t, router, token, "POST", "/attempts", models.StartAttemptRequest{}, nil, http.StatusUnprocessableEntity)
+}
+
+func TestRevisions(t *testing.T) {
+	router, token := newTestApp(t)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(question.ID)
+	question.Body = "Where does the sun rise?"
+	request(t, router, token, "PUT", path, question, &question, http.StatusOK)
+
+	var revisions []models.Revision
+	request(t, router, token, "GET", path+"/revisions", nil, &revisions, http.StatusOK)
+	if len(revisions) != 2 || revisions[1].Question.Body != "Where does the sun rise?" {
+		t.Fatalf("got %+v", revisions)
+	}
+	var diff models.RevisionDiff
+	request(t, router, token, "GET", path+"/revisions/diff?from=1&to=2", nil, &diff, http.StatusOK)
+	if len(diff.Changes) != 1 || diff.Changes[0].Field != "body" || diff.Changes[0].From != sunQuestion.Body {
+		t.Fatalf("got %+v", diff)
+	}
+	request(t, router, token, "GET", path+"/revisions/diff?from=1", nil, nil, http.StatusBadRequest)
+	var revision models.Revision
+	request(t, router, token, "GET", path+"/revisions/1", nil, &revision, http.StatusOK)
+	if revision.Question.Body != sunQuestion.Body {
+		t.Fatalf("got %+v", revision)
+	}
+	request(t, router, token, "POST", path+"/revisions/1/restore", nil, &question, http.StatusOK)
+	if question.Body != sunQuestion.Body {
+		t.Fatalf("got %+v", question)
+	}
+	request(t, router, token, "POST", path+"/revisions/4/restore", nil, nil, http.StatusNotFound)
+}
+
+func TestTrash(t *testing.T) {
+	router, token := newTestApp(t)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(question.ID)
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusNoContent)
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusNotFound)
+	request(t, router, token, "DELETE", "/questions/0", nil, nil, http.StatusNotFound)
+
+	var trash []models.Question
+	request(t, router, token, "GET", "/questions/trash", nil, &trash, http.StatusOK)
+	if len(trash) != 1 || trash[0].ID != question.ID || trash[0].DeletedAt == nil {
+		t.Fatalf("got %+v", trash)
+	}
+	request(t, router, token, "POST", path+"/restore", nil, &question, http.StatusOK)
+	if question.DeletedAt != nil {
+		t.Fatalf("got %+v", question)
+	}
+	request(t, router, token, "POST", path+"/restore", nil, nil, http.StatusNotFound)
+	request(t, router, token, "GET", path, nil, nil, http.StatusOK)
+}
+
+// conditionalRequest sends body as JSON to handler with an If-Match header and returns the recorded response
+func conditionalRequest(t *testing.T, handler http.Handler, token, method, path, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestETags(t *testing.T) {
+	router, token := newTestApp(t)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(question.ID)
+
+	w := conditionalRequest(t, router, token, "GET", path, "", nil)
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("got ETag %s", etag)
+	}
+	question.Body = "Where does the sun rise?"
+	w = conditionalRequest(t, router, token, "PUT", path, `"1"`, question)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("got status %d and ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		w = conditionalRequest(t, router, token, method, path, `"1"`, question)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("%s: got status %d, want 412: %s", method, w.Code, w.Body.String())
+		}
+	}
+	w = conditionalRequest(t, router, token, "POST", path+"/options", `"1"`, models.Option{Body: "North"})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("got status %d, want 412", w.Code)
+	}
+	w = conditionalRequest(t, router, token, "PUT", path, "yesterday", question)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400", w.Code)
+	}
+	w = conditionalRequest(t, router, token, "DELETE", path, `W/"2"`, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	router, token := newConfiguredTestApp(t, App{RequireIfMatch: true})
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(question.ID)
+
+	request(t, router, token, "PUT", path, question, nil, http.StatusPreconditionRequired)
+	request(t, router, token, "DELETE", path, nil, nil, http.StatusPreconditionRequired)
+	w := conditionalRequest(t, router, token, "DELETE", path, "*", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", w.Code)
+	}
+}
+
+func TestSharing(t *testing.T) {
+	router, token := newTestApp(t)
+	bob := models.User{Username: "bob", Password: "password"}
+	var created models.UserResponse
+	request(t, router, "", "POST", "/users", bob, &created, http.StatusOK)
+	var bobToken models.JWTTokenResponse
+	request(t, router, "", "POST", "/users/token", bob, &bobToken, http.StatusOK)
+	var question models.Question
+	request(t, router, token, "POST", "/questions", sunQuestion, &question, http.StatusOK)
+	path := "/questions/" + strconv.Itoa(question.ID)
+
+	request(t, router, bobToken.Token, "GET", path, nil, nil, http.StatusForbidden)
+	var share models.Share
+	viewer := models.ShareRequest{Username: "bob", Role: models.RoleViewer}
+	request(t, router, token, "POST", path+"/shares", viewer, &share, http.StatusOK)
+	if share.UserID != created.ID || share.Role != models.RoleViewer {
+		t.Fatalf("got %+v", share)
+	}
+	request(t, router, bobToken.Token, "GET", path, nil, nil, http.StatusOK)
+	request(t, router, bobToken.Token, "PUT", path, question, nil, http.StatusForbidden)
+	request(t, router, token, "POST", path+"/shares", models.ShareRequest{Username: "bob"}, nil, http.StatusUnprocessableEntity)
+	request(t, router, token, "POST", path+"/shares", models.ShareRequest{Username: "alice", Role: models.RoleEditor}, nil, http.StatusConflict)
+
+	var shares []models.Share
+	request(t, router, bobToken.Token, "GET", path+"/shares", nil, &shares, http.StatusOK)
+	if len(shares) != 1 || shares[0].Username != "bob" {
+		t.Fatalf("got %+v", shares)
+	}
+	sharePath := path + "/shares/" + strconv.Itoa(created.ID)
+	request(t, router, token, "DELETE", sharePath, nil, nil, http.StatusNoContent)
+	request(t, router, token, "DELETE", sharePath, nil, nil, http.StatusNotFound)
+	request(t, router, bobToken.Token, "GET", path, nil, nil, http.StatusForbidden)
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/responses"
+	"github.com/makupi/backend-homework/storage"
+	"log"
+	"net/http"
+)
+
+// AttemptMiddleware holds the secret and issuer needed for the attempt token Middleware
+type AttemptMiddleware struct {
+	Secret []byte
+	Issuer string
+}
+
+// Middleware that checks for an attempt token created by storage.NewAttemptToken
+// Tokens must be signed with HS256, not be expired, come from Issuer and have the attempt scope
+// The attempt ID of the token will be set in r.Context
+func (a *AttemptMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := scopedClaims(r, a.Secret, a.Issuer); ok && claims["scope"] == storage.TokenScopeAttempt {
+			if attemptID, ok := claims["attemptID"].(float64); ok {
+				ctx := context.WithValue(r.Context(), models.ContextAttemptID, int(attemptID))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		log.Print("Unauthorized attempt access to " + r.Method + " " + r.RequestURI)
+		responses.Error(w, r, responses.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/responses"
+	"github.com/makupi/backend-homework/storage"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeliveryMiddleware holds the secret and issuer needed for the delivery token Middleware
+type DeliveryMiddleware struct {
+	Secret []byte
+	Issuer string
+}
+
+// Middleware that checks for a delivery token created by storage.NewDeliveryToken
+// Tokens must be signed with HS256, not be expired, come from Issuer and have the delivery scope,
+// access tokens are rejected
+// The models.DeliveryScope of the token will be set in r.Context
+func (d *DeliveryMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := scopedClaims(r, d.Secret, d.Issuer); ok {
+			if scope, ok := deliveryScope(claims); ok {
+				ctx := context.WithValue(r.Context(), models.ContextDeliveryScope, scope)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		log.Print("Unauthorized delivery access to " + r.Method + " " + r.RequestURI)
+		responses.Error(w, r, responses.ErrUnauthorized)
+	})
+}
+
+// scopedClaims returns the claims of the bearer token of r
+// It fails if the token isn't signed with HS256 and secret, is expired or doesn't come from issuer
+func scopedClaims(r *http.Request, secret []byte, issuer string) (jwt.MapClaims, bool) {
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, err := jwt.Parse(bearer, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) || !claims.VerifyIssuer(issuer, true) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// deliveryScope reads the DeliveryScope out of claims, it fails if the token doesn't have the delivery scope
+func deliveryScope(claims jwt.MapClaims) (models.DeliveryScope, bool) {
+	if claims["scope"] != storage.TokenScopeDelivery {
+		return models.DeliveryScope{}, false
+	}
+	authorID, ok := claims["authorID"].(float64)
+	if !ok {
+		return models.DeliveryScope{}, false
+	}
+	encodedSeed, _ := claims["seed"].(string)
+	seed, err := strconv.ParseInt(encodedSeed, 10, 64)
+	if err != nil {
+		return models.DeliveryScope{}, false
+	}
+	ids, ok := claims["question_ids"].([]interface{})
+	if !ok {
+		return models.DeliveryScope{}, false
+	}
+	scope := models.DeliveryScope{AuthorID: int(authorID), Seed: seed}
+	for _, id := range ids {
+		id, ok := id.(float64)
+		if !ok {
+			return models.DeliveryScope{}, false
+		}
+		scope.QuestionIDs = append(scope.QuestionIDs, int(id))
+	}
+	return scope, true
+}
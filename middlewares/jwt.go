@@ -5,40 +5,53 @@ import (
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/responses"
 	"github.com/makupi/backend-homework/storage"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// JWTMiddleware holds the secret and storage needed for the JWT Middleware
+// JWTMiddleware holds the secret, issuer and storage needed for the JWT Middleware
 type JWTMiddleware struct {
 	Secret  []byte
+	Issuer  string
 	Storage storage.Storage
 }
 
 // Middleware that checks for a JWT token and verifies if the userID inside exists
-// "userID" will be set in r.Context
+// Tokens must be signed with HS256, not be expired, come from Issuer and not be revoked
+// "userID", "jti" and the expiry of the token will be set in r.Context
 func (j *JWTMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		auth := r.Header.Get("Authorization")
 		bearer := strings.TrimPrefix(auth, "Bearer ")
 		token, err := jwt.Parse(bearer, func(token *jwt.Token) (interface{}, error) {
+			if token.Method != jwt.SigningMethodHS256 {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
 			return j.Secret, nil
 		})
 		if err == nil && token.Valid {
-			userID := (*token).Claims.(jwt.MapClaims)["userID"]
-			if userID != nil {
+			claims := (*token).Claims.(jwt.MapClaims)
+			userID := claims["userID"]
+			jti, _ := claims["jti"].(string)
+			exp, _ := claims["exp"].(float64)
+			valid := claims.VerifyExpiresAt(time.Now().Unix(), true) && claims.VerifyIssuer(j.Issuer, true)
+			if userID != nil && valid && jti != "" && !j.Storage.IsTokenRevoked(jti) {
 				userID, err := strconv.Atoi(fmt.Sprintf("%v", userID))
 				if err == nil && j.Storage.UserIDExists(userID) {
 					ctx := context.WithValue(r.Context(), models.ContextUserID, userID)
+					ctx = context.WithValue(ctx, models.ContextTokenID, jti)
+					ctx = context.WithValue(ctx, models.ContextTokenExpiry, time.Unix(int64(exp), 0))
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
 			}
 		}
 		log.Print("Unauthorized access to " + r.Method + " " + r.RequestURI)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		responses.Error(w, r, responses.ErrUnauthorized)
 	})
 }
@@ -0,0 +1,80 @@
+package middlewares_test
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/makupi/backend-homework/middlewares"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/storage"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+// newToken signs claims for userID with secret, overrides replace the defaults
+func newToken(t *testing.T, userID int, overrides jwt.MapClaims) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"userID": userID,
+		"iss":    "test",
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"jti":    "token-id",
+	}
+	for key, value := range overrides {
+		claims[key] = value
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	s := storage.NewMemoryStorage(storage.NewPasswordHasher(bcrypt.MinCost))
+	user, err := s.CreateUser("alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RevokeTokens(user.ID, "", "revoked-id", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := middlewares.JWTMiddleware{Secret: secret, Issuer: "test", Storage: s}
+	handler := j.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(models.ContextUserID) != user.ID {
+			t.Errorf("got userID %v, want %d", r.Context().Value(models.ContextUserID), user.ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := map[string]struct {
+		token  string
+		status int
+	}{
+		"valid":        {newToken(t, user.ID, nil), http.StatusNoContent},
+		"missing":      {"", http.StatusUnauthorized},
+		"expired":      {newToken(t, user.ID, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		"revoked":      {newToken(t, user.ID, jwt.MapClaims{"jti": "revoked-id"}), http.StatusUnauthorized},
+		"wrong issuer": {newToken(t, user.ID, jwt.MapClaims{"iss": "other"}), http.StatusUnauthorized},
+		"no jti":       {newToken(t, user.ID, jwt.MapClaims{"jti": ""}), http.StatusUnauthorized},
+		"unknown user": {newToken(t, user.ID+1, nil), http.StatusUnauthorized},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.token != "" {
+				r.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Fatalf("got status %d, want %d", w.Code, test.status)
+			}
+		})
+	}
+}
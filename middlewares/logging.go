@@ -1,14 +1,15 @@
 package middlewares
 
 import (
+	"github.com/makupi/backend-homework/models"
 	"log"
 	"net/http"
 )
 
-// LoggingMiddleware is a simple logging middleware that logs "<Method> <URI>" before executing the request
+// LoggingMiddleware is a simple logging middleware that logs "<RequestID> <Method> <URI>" before executing the request
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println(r.Method, r.RequestURI)
+		log.Println(r.Context().Value(models.ContextRequestID), r.Method, r.RequestURI)
 		next.ServeHTTP(w, r)
 	})
 }
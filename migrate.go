@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/makupi/backend-homework/storage"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = `usage: backend-homework migrate <command>
+
+commands:
+  status          print the current and latest schema version
+  up [version]    apply migrations up to version, defaults to the latest
+  down [version]  revert migrations down to version, defaults to the previous one`
+
+// runMigrate is the entry point for the migrate subcommand
+func runMigrate(migrator *storage.Migrator, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	// -1 selects the default target of the command
+	target := -1
+	if len(args) == 2 {
+		target, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+	}
+	switch args[0] {
+	case "status":
+		log.Printf("Schema version %d, latest is %d", version, migrator.Latest())
+		return nil
+	case "up":
+		if target == -1 {
+			target = migrator.Latest()
+		}
+		return migrator.Up(target)
+	case "down":
+		if target == -1 && version > 0 {
+			target = version - 1
+		}
+		return migrator.Down(target)
+	}
+	return errors.New(migrateUsage)
+}
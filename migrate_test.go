@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/makupi/backend-homework/storage"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunMigrate(t *testing.T) {
+	migrator, err := storage.NewSqliteMigrator(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer migrator.DB.Close()
+	assertVersion := func(want int) {
+		t.Helper()
+		version, err := migrator.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want {
+			t.Fatalf("got version %d, want %d", version, want)
+		}
+	}
+
+	steps := []struct {
+		args    []string
+		version int
+	}{
+		{[]string{"status"}, 0},
+		{[]string{"up", "2"}, 2},
+		{[]string{"up"}, migrator.Latest()},
+		{[]string{"down"}, migrator.Latest() - 1},
+		{[]string{"down", "3"}, 3},
+		{[]string{"status"}, 3},
+		{[]string{"down", "0"}, 0},
+		{[]string{"up"}, migrator.Latest()},
+	}
+	for _, step := range steps {
+		err := runMigrate(migrator, step.args)
+		if err != nil {
+			t.Fatalf("%v: %v", step.args, err)
+		}
+		assertVersion(step.version)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"sideways"},
+		{"up", "latest"},
+		{"up", "1", "2"},
+		{"up", "1000"},
+		{"down", "-2"},
+	} {
+		err := runMigrate(migrator, args)
+		if err == nil {
+			t.Fatalf("%v: want error", args)
+		}
+	}
+	assertVersion(migrator.Latest())
+}
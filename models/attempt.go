@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Attempt statuses, expired attempts were submitted automatically when their time ran out
+const (
+	AttemptInProgress = "in_progress"
+	AttemptSubmitted  = "submitted"
+	AttemptExpired    = "expired"
+)
+
+// Attempt is the JSON representation of a candidate taking a set of questions
+// QuestionIDs are served in order, Points holds the points every question was worth when the attempt started
+// and Questions the questions themselves, so changing, deleting or unsharing them doesn't affect the attempt
+// TimeLimit is in seconds, 0 means no limit, ExpiresAt is only set with a limit
+// Score, MaxScore and Percentage are final once the attempt isn't in progress anymore,
+// Passed is only set for finished attempts of a test
+type Attempt struct {
+	ID            int             `json:"id"`
+	TestID        int             `json:"test_id,omitempty"`
+	Candidate     string          `json:"candidate"`
+	Status        string          `json:"status"`
+	QuestionIDs   []int           `json:"question_ids"`
+	Points        []int           `json:"-"`
+	Questions     []Question      `json:"-"`
+	TimeLimit     int             `json:"time_limit"`
+	PassThreshold int             `json:"pass_threshold"`
+	StartedAt     time.Time       `json:"started_at"`
+	ExpiresAt     *time.Time      `json:"expires_at"`
+	SubmittedAt   *time.Time      `json:"submitted_at"`
+	Answers       []AttemptAnswer `json:"answers"`
+	Score         float64         `json:"score"`
+	MaxScore      float64         `json:"max_score"`
+	Percentage    float64         `json:"percentage"`
+	Passed        *bool           `json:"passed,omitempty"`
+	AuthorID      int             `json:"-"`
+}
+
+// AttemptAnswer is the JSON representation of a recorded answer, it is graded when it is recorded
+type AttemptAnswer struct {
+	QuestionID int       `json:"question_id"`
+	OptionIDs  []int     `json:"option_ids,omitempty"`
+	Answer     string    `json:"answer,omitempty"`
+	Value      *float64  `json:"value,omitempty"`
+	AnsweredAt time.Time `json:"answered_at"`
+	Correct    bool      `json:"correct"`
+	Points     float64   `json:"points"`
+}
+
+// StartAttemptRequest is the JSON representation for starting an attempt
+// Attempts are either started for a test, using its questions, time limit and pass threshold,
+// or for QuestionIDs with an optional TimeLimit
+type StartAttemptRequest struct {
+	TestID      int    `json:"test_id"`
+	QuestionIDs []int  `json:"question_ids"`
+	TimeLimit   int    `json:"time_limit"`
+	Candidate   string `json:"candidate"`
+}
+
+// AttemptTokenResponse is the JSON representation of a started attempt and the token for its candidate
+type AttemptTokenResponse struct {
+	Attempt   Attempt `json:"attempt"`
+	Token     string  `json:"token"`
+	TokenType string  `json:"token_type"`
+	ExpiresIn int     `json:"expires_in"`
+}
+
+// AttemptProgress is the JSON representation of an attempt for its candidate, it never contains results
+type AttemptProgress struct {
+	ID        int        `json:"id"`
+	Status    string     `json:"status"`
+	Answered  int        `json:"answered"`
+	Total     int        `json:"total"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AnswerRequest is the JSON representation of a candidate answering the current question of an attempt
+type AnswerRequest struct {
+	QuestionID int `json:"question_id"`
+	CheckRequest
+}
+
+// ErrNoQuestionsLeft is returned when every question of an attempt has been answered
+var ErrNoQuestionsLeft = errors.New("every question has been answered")
+
+// Validate checks that exactly one of TestID and QuestionIDs is set and the time limit is valid
+func (r StartAttemptRequest) Validate() error {
+	var errs ValidationErrors
+	if (r.TestID == 0) == (len(r.QuestionIDs) == 0) {
+		errs = append(errs, ValidationError{Field: "test_id", Message: "either test_id or question_ids must be set"})
+	}
+	seen := make(map[int]bool, len(r.QuestionIDs))
+	for _, id := range r.QuestionIDs {
+		if seen[id] {
+			errs = append(errs, ValidationError{Field: "question_ids", Message: "must be unique"})
+			break
+		}
+		seen[id] = true
+	}
+	if r.TimeLimit < 0 || r.TimeLimit > MaxTestTimeLimit {
+		errs = append(errs, ValidationError{
+			Field:   "time_limit",
+			Message: fmt.Sprintf("must be between 0 and %d seconds", MaxTestTimeLimit),
+		})
+	} else if r.TestID != 0 && r.TimeLimit != 0 {
+		errs = append(errs, ValidationError{Field: "time_limit", Message: "is taken from the test"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Progress returns the view of the attempt for its candidate
+func (a Attempt) Progress() AttemptProgress {
+	return AttemptProgress{
+		ID:        a.ID,
+		Status:    a.Status,
+		Answered:  len(a.Answers),
+		Total:     len(a.QuestionIDs),
+		StartedAt: a.StartedAt,
+		ExpiresAt: a.ExpiresAt,
+	}
+}
+
+// CurrentQuestion returns the first question that hasn't been answered yet as it was when the attempt started
+// If every question has been answered it will result in ErrNoQuestionsLeft
+func (a Attempt) CurrentQuestion() (Question, error) {
+	if len(a.Answers) < len(a.Questions) {
+		return a.Questions[len(a.Answers)], nil
+	}
+	return Question{}, ErrNoQuestionsLeft
+}
+
+// Expired checks if the time of an attempt in progress ran out at now
+func (a Attempt) Expired(now time.Time) bool {
+	return a.Status == AttemptInProgress && a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
+
+// Finish closes the attempt with status at submittedAt and calculates its final score
+func (a *Attempt) Finish(status string, submittedAt time.Time) {
+	a.Status = status
+	a.SubmittedAt = &submittedAt
+	a.Calculate()
+}
+
+// Calculate sets Score, MaxScore and Percentage from Points and Answers
+// Passed is only set for finished attempts of a test
+func (a *Attempt) Calculate() {
+	a.Score, a.MaxScore, a.Percentage, a.Passed = 0, 0, 0, nil
+	for _, points := range a.Points {
+		a.MaxScore += float64(points)
+	}
+	for _, answer := range a.Answers {
+		a.Score += answer.Points
+	}
+	if a.MaxScore > 0 {
+		a.Percentage = 100 * a.Score / a.MaxScore
+	}
+	if a.TestID != 0 && a.Status != AttemptInProgress {
+		passed := a.Percentage >= float64(a.PassThreshold)
+		a.Passed = &passed
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import "fmt"
+
+// CheckRequest is the JSON representation of a candidate response to a question
+// Choice questions are answered with OptionIDs, free text questions with Answer and numeric questions with Value
+type CheckRequest struct {
+	OptionIDs []int    `json:"option_ids"`
+	Answer    string   `json:"answer"`
+	Value     *float64 `json:"value"`
+}
+
+// CheckResult is the JSON representation of a graded response
+// Score is the partial credit between 0 and 1, Points the share of the points of the question it is worth
+// The answer key of the question is included depending on its type
+type CheckResult struct {
+	Correct         bool             `json:"correct"`
+	Score           float64          `json:"score"`
+	Points          float64          `json:"points"`
+	CorrectOptions  []Option         `json:"correct_options,omitempty"`
+	AcceptedAnswers []AcceptedAnswer `json:"accepted_answers,omitempty"`
+	NumericAnswer   *NumericAnswer   `json:"numeric_answer,omitempty"`
+	Explanation     string           `json:"explanation,omitempty"`
+}
+
+// Check grades response against the answer key of the question
+// Multiple choice questions get partial credit: every correct option selected counts, every wrong one cancels one
+// of them out, the score never drops below 0
+// If response selects options that don't belong to the question it will result in ValidationErrors
+func (q Question) Check(response CheckRequest) (CheckResult, error) {
+	result := CheckResult{Explanation: q.Explanation}
+	switch {
+	case HasOptions(q.Type):
+		score, err := q.checkOptions(response.OptionIDs)
+		if err != nil {
+			return CheckResult{}, err
+		}
+		result.Score = score
+		for _, option := range q.Options {
+			if option.Correct {
+				result.CorrectOptions = append(result.CorrectOptions, option)
+			}
+		}
+	case q.Type == QuestionTypeFreeText:
+		for _, answer := range q.AcceptedAnswers {
+			if answer.Matches(response.Answer) {
+				result.Score = 1
+			}
+		}
+		result.AcceptedAnswers = q.AcceptedAnswers
+	case q.Type == QuestionTypeNumeric:
+		if response.Value != nil && q.NumericAnswer != nil && q.NumericAnswer.Matches(*response.Value) {
+			result.Score = 1
+		}
+		result.NumericAnswer = q.NumericAnswer
+	}
+	result.Correct = result.Score == 1
+	result.Points = result.Score * float64(q.Points)
+	return result, nil
+}
+
+// checkOptions returns the score of selecting optionIDs, duplicates are ignored
+func (q Question) checkOptions(optionIDs []int) (float64, error) {
+	correct := make(map[int]bool, len(q.Options))
+	for _, option := range q.Options {
+		correct[option.ID] = option.Correct
+	}
+	var errs ValidationErrors
+	selected := make(map[int]bool, len(optionIDs))
+	hits, misses := 0, 0
+	for i, id := range optionIDs {
+		isCorrect, ok := correct[id]
+		if !ok {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("option_ids[%d]", i), Message: "does not belong to this question"})
+			continue
+		}
+		if selected[id] {
+			continue
+		}
+		selected[id] = true
+		if isCorrect {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	total := 0
+	for _, isCorrect := range correct {
+		if isCorrect {
+			total++
+		}
+	}
+	if q.Type != QuestionTypeMultipleChoice {
+		if hits == 1 && misses == 0 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if total == 0 || hits <= misses {
+		return 0, nil
+	}
+	return float64(hits-misses) / float64(total), nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// MaxDeliveryQuestions is the maximum number of questions a single delivery token grants access to
+const MaxDeliveryQuestions = 100
+
+// DeliveryScope is what a delivery token grants access to
+// Candidates can only read the QuestionIDs of AuthorID, options are shuffled with Seed
+type DeliveryScope struct {
+	AuthorID    int
+	QuestionIDs []int
+	Seed        int64
+}
+
+// Allows checks if questionID is part of the scope
+func (d DeliveryScope) Allows(questionID int) bool {
+	for _, id := range d.QuestionIDs {
+		if id == questionID {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryTokenRequest is the JSON representation for creating a delivery token
+// Seed is chosen randomly if it is missing, ExpiresIn is in seconds and defaults to the configured lifetime
+type DeliveryTokenRequest struct {
+	QuestionIDs []int  `json:"question_ids"`
+	Seed        *int64 `json:"seed"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Validate checks that the request contains between 1 and MaxDeliveryQuestions questions and ExpiresIn isn't negative
+func (d DeliveryTokenRequest) Validate() error {
+	var errs ValidationErrors
+	if len(d.QuestionIDs) == 0 || len(d.QuestionIDs) > MaxDeliveryQuestions {
+		errs = append(errs, ValidationError{
+			Field:   "question_ids",
+			Message: fmt.Sprintf("must contain between 1 and %d questions", MaxDeliveryQuestions),
+		})
+	}
+	if d.ExpiresIn < 0 {
+		errs = append(errs, ValidationError{Field: "expires_in", Message: "must not be negative"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DeliveryTokenResponse is the JSON representation for created delivery tokens
+// The same seed always results in the same order of options
+type DeliveryTokenResponse struct {
+	Token       string `json:"token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	QuestionIDs []int  `json:"question_ids"`
+	Seed        int64  `json:"seed"`
+}
+
+// DeliveredQuestion is the JSON representation of a question for candidates, it never contains the answer key
+type DeliveredQuestion struct {
+	ID         int               `json:"id"`
+	Body       string            `json:"body"`
+	Type       string            `json:"type"`
+	Options    []DeliveredOption `json:"options"`
+	Difficulty string            `json:"difficulty"`
+	TimeLimit  int               `json:"time_limit"`
+	Points     int               `json:"points"`
+}
+
+// DeliveredOption is the JSON representation of an option for candidates
+type DeliveredOption struct {
+	ID       int    `json:"id"`
+	Body     string `json:"body"`
+	Position int    `json:"position"`
+}
+
+// Deliver returns the question as it is shown to candidates
+// If ShuffleOptions is set the options are shuffled, the order only depends on seed and the question
+func (q Question) Deliver(seed int64) DeliveredQuestion {
+	delivered := DeliveredQuestion{
+		ID:         q.ID,
+		Body:       q.Body,
+		Type:       q.Type,
+		Options:    make([]DeliveredOption, len(q.Options)),
+		Difficulty: q.Difficulty,
+		TimeLimit:  q.TimeLimit,
+		Points:     q.Points,
+	}
+	for i, option := range q.Options {
+		delivered.Options[i] = DeliveredOption{ID: option.ID, Body: option.Body}
+	}
+	if q.ShuffleOptions {
+		random := rand.New(rand.NewSource(seed ^ int64(q.ID)))
+		random.Shuffle(len(delivered.Options), func(i, j int) {
+			delivered.Options[i], delivered.Options[j] = delivered.Options[j], delivered.Options[i]
+		})
+	}
+	for i := range delivered.Options {
+		delivered.Options[i].Position = i
+	}
+	return delivered
+}
@@ -0,0 +1,10 @@
+package models
+
+// ErrorResponse is the JSON representation for errors over the REST API
+// Details is only set for errors that carry more information, like ValidationErrors
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id"`
+	Details   interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// QuestionExport is the JSON representation of an export of questions with all their metadata
+type QuestionExport struct {
+	ExportedAt time.Time  `json:"exported_at"`
+	Total      int        `json:"total"`
+	Questions  []Question `json:"questions"`
+}
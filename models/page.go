@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Sort orders for listing questions
+const (
+	SortNewest  = "newest"
+	SortOldest  = "oldest"
+	SortUpdated = "updated"
+	// SortRelevance is only available for searches
+	SortRelevance = "relevance"
+)
+
+const (
+	// DefaultPageSize is the number of questions per page if no limit is requested
+	DefaultPageSize = 20
+	// MaxPageSize is the maximum number of questions per page
+	MaxPageSize = 100
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a question of a listing, the page starts right after it
+// or ends right before it if Before is set
+// UpdatedAt is only set for SortUpdated and Rank only for SortRelevance
+type Cursor struct {
+	Sort      string    `json:"s"`
+	ID        int       `json:"i"`
+	UpdatedAt time.Time `json:"u"`
+	Rank      float64   `json:"r,omitempty"`
+	Before    bool      `json:"b,omitempty"`
+}
+
+// NewCursor returns the cursor pointing at question within a listing in sort order
+func NewCursor(sort string, question Question, before bool) Cursor {
+	cursor := Cursor{Sort: sort, ID: question.ID, Before: before}
+	switch sort {
+	case SortUpdated:
+		cursor.UpdatedAt = question.UpdatedAt
+	case SortRelevance:
+		cursor.Rank = question.Rank
+	}
+	return cursor
+}
+
+// Encode returns the opaque string representation of c
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode, sort is the order of the listing it is used for
+// If the cursor is malformed or belongs to a different order it will result in ErrInvalidCursor
+func DecodeCursor(encoded, sort string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.Sort != sort || cursor.ID <= 0 {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// PageRequest describes which page of a listing is requested
+// Cursor is nil for the first page, Query is nil if the listing isn't a search
+// Tags are normalized tag names, the listing is filtered by them according to TagMode
+// Difficulties only lists questions with one of them, zero bounds on points and time limit are ignored
+// UpdatedSince only lists questions changed at or after it unless it is zero
+type PageRequest struct {
+	Sort         string
+	Limit        int
+	Cursor       *Cursor
+	Query        *SearchQuery
+	Tags         []string
+	TagMode      string
+	Difficulties []string
+	MinPoints    int
+	MaxPoints    int
+	MaxTimeLimit int
+	UpdatedSince time.Time
+}
+
+// QuestionPage is the JSON representation of a page of questions
+// NextCursor and PrevCursor are empty if there is no next or previous page
+type QuestionPage struct {
+	Questions  []Question `json:"questions"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	Total      int        `json:"total"`
+	Limit      int        `json:"limit"`
+}
+
+// NewQuestionPage builds the page for request out of up to request.Limit+1 questions in the order they were fetched,
+// the extra question only signals that there are more
+// Questions fetched for a cursor with Before set are expected in reverse order
+func NewQuestionPage(request PageRequest, questions []Question, total int) QuestionPage {
+	more := len(questions) > request.Limit
+	if more {
+		questions = questions[:request.Limit]
+	}
+	before := request.Cursor != nil && request.Cursor.Before
+	if before {
+		for i, j := 0, len(questions)-1; i < j; i, j = i+1, j-1 {
+			questions[i], questions[j] = questions[j], questions[i]
+		}
+	}
+	page := QuestionPage{Questions: questions, Total: total, Limit: request.Limit}
+	if len(questions) == 0 {
+		return page
+	}
+	// a page fetched backwards ends right before the cursor question, so there is always a next page
+	if more || before {
+		page.NextCursor = NewCursor(request.Sort, questions[len(questions)-1], false).Encode()
+	}
+	if (more && before) || (request.Cursor != nil && !before) {
+		page.PrevCursor = NewCursor(request.Sort, questions[0], true).Encode()
+	}
+	return page
+}
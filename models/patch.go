@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrPatchNotObject is returned when a merge patch would replace the whole question instead of changing its fields
+var ErrPatchNotObject = errors.New("merge patch must be a JSON object")
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396) to the JSON representation of question
+// Arrays like options are replaced as a whole, so options that should be kept need to be included with their ID
+// patch has to be an object, otherwise it results in ErrPatchNotObject
+func ApplyMergePatch(question Question, patch []byte) (Question, error) {
+	var patchValue interface{}
+	err := json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return question, err
+	}
+	if _, ok := patchValue.(map[string]interface{}); !ok {
+		return question, ErrPatchNotObject
+	}
+	original, err := json.Marshal(question)
+	if err != nil {
+		return question, err
+	}
+	var target interface{}
+	err = json.Unmarshal(original, &target)
+	if err != nil {
+		return question, err
+	}
+	merged, err := json.Marshal(mergePatch(target, patchValue))
+	if err != nil {
+		return question, err
+	}
+	var patched Question
+	err = json.Unmarshal(merged, &patched)
+	return patched, err
+}
+
+// mergePatch implements the MergePatch function of RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	question := Question{
+		ID:            1,
+		Body:          "How far is the moon?",
+		Type:          "numeric",
+		NumericAnswer: &NumericAnswer{Value: 384400, Tolerance: 1000},
+		Difficulty:    "easy",
+		Points:        1,
+		Explanation:   "About 30 times the diameter of earth",
+		Tags:          []string{"astronomy", "space"},
+		Version:       2,
+	}
+	tests := map[string]struct {
+		patch string
+		want  func(q *Question)
+	}{
+		"empty": {`{}`, func(q *Question) {}},
+		"set field": {`{"body": "How far away is the moon?"}`, func(q *Question) {
+			q.Body = "How far away is the moon?"
+		}},
+		"remove with null": {`{"explanation": null, "numeric_answer": null}`, func(q *Question) {
+			q.Explanation = ""
+			q.NumericAnswer = nil
+		}},
+		"merge nested object": {`{"numeric_answer": {"tolerance": 500}}`, func(q *Question) {
+			q.NumericAnswer = &NumericAnswer{Value: 384400, Tolerance: 500}
+		}},
+		"remove nested key with null": {`{"numeric_answer": {"tolerance": null}}`, func(q *Question) {
+			q.NumericAnswer = &NumericAnswer{Value: 384400}
+		}},
+		"replace array": {`{"tags": ["moon"]}`, func(q *Question) {
+			q.Tags = []string{"moon"}
+		}},
+		"replace array with empty array": {`{"tags": []}`, func(q *Question) {
+			q.Tags = []string{}
+		}},
+		"unknown field": {`{"color": "grey"}`, func(q *Question) {}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			want := question
+			want.NumericAnswer = &NumericAnswer{Value: 384400, Tolerance: 1000}
+			want.Tags = append([]string{}, question.Tags...)
+			test.want(&want)
+			got, err := ApplyMergePatch(question, []byte(test.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+	if question.NumericAnswer.Tolerance != 1000 || question.Tags[0] != "astronomy" {
+		t.Fatalf("patch modified the original question: %+v", question)
+	}
+}
+
+func TestApplyMergePatchInvalid(t *testing.T) {
+	question := Question{ID: 1, Body: "How far is the moon?"}
+	for _, patch := range []string{`null`, `[]`, `"body"`, `1`, `true`} {
+		got, err := ApplyMergePatch(question, []byte(patch))
+		if !errors.Is(err, ErrPatchNotObject) {
+			t.Fatalf("%s: got error %v, want %v", patch, err, ErrPatchNotObject)
+		}
+		if !reflect.DeepEqual(got, question) {
+			t.Fatalf("%s: got %+v, want %+v", patch, got, question)
+		}
+	}
+	for _, patch := range []string{``, `{`, `{"body": 1}`, `{"options": {"body": "Far"}}`} {
+		_, err := ApplyMergePatch(question, []byte(patch))
+		if err == nil {
+			t.Fatalf("%s: want error", patch)
+		}
+	}
+}
@@ -1,16 +1,89 @@
 package models
 
+import "time"
+
+// Question difficulties, questions without a difficulty get DefaultDifficulty
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+
+	DefaultDifficulty = DifficultyMedium
+)
+
+// Limits of the scoring metadata of a question
+const (
+	// DefaultPoints are awarded for questions that don't set points
+	DefaultPoints = 1
+	MaxPoints     = 1000
+	// MaxTimeLimit is the longest suggested time to answer a question in seconds
+	MaxTimeLimit         = 3600
+	MaxExplanationLength = 5000
+)
+
 // Question is the JSON representation for questions over the REST API
+// Type decides how the question is answered, choice questions have Options, free text questions AcceptedAnswers
+// and numeric questions a NumericAnswer
+// ShuffleOptions requests that options are shuffled when the question is delivered to candidates,
+// authors always get the options in their stored order
+// TimeLimit is the suggested time to answer in seconds, 0 means no limit
+// Explanation is shown to candidates after answering
+// CreatedAt, UpdatedAt, CreatedBy and UpdatedBy are maintained by the storage and ignored in requests,
+// UpdatedBy is the user who made the last change, DeletedAt is only set for questions in the trash
+// Version counts the changes of the question starting at 1, it is sent as ETag
+// Tags are case-insensitive and sorted by name, unknown tags are created
+// Snippet and Rank are only set in search results, Snippet is the HTML-escaped matching text with matches wrapped in <mark>
 type Question struct {
-	ID      int      `json:"id"`
-	Body    string   `json:"body"`
-	Options []Option `json:"options"`
+	ID              int              `json:"id"`
+	Body            string           `json:"body"`
+	Type            string           `json:"type"`
+	Options         []Option         `json:"options"`
+	AcceptedAnswers []AcceptedAnswer `json:"accepted_answers,omitempty"`
+	NumericAnswer   *NumericAnswer   `json:"numeric_answer,omitempty"`
+	ShuffleOptions  bool             `json:"shuffle_options"`
+	Difficulty      string           `json:"difficulty"`
+	TimeLimit       int              `json:"time_limit"`
+	Points          int              `json:"points"`
+	Explanation     string           `json:"explanation"`
+	Tags            []string         `json:"tags"`
+	Version         int              `json:"version"`
+	Snippet         string           `json:"snippet,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	CreatedBy       int              `json:"created_by"`
+	UpdatedBy       int              `json:"updated_by"`
+	DeletedAt       *time.Time       `json:"deleted_at,omitempty"`
+	Rank            float64          `json:"-"`
+}
+
+// WithDefaults returns the question with DefaultQuestionType, DefaultDifficulty and DefaultPoints set if they are missing
+func (q Question) WithDefaults() Question {
+	if q.Type == "" {
+		q.Type = DefaultQuestionType
+	}
+	if q.Difficulty == "" {
+		q.Difficulty = DefaultDifficulty
+	}
+	if q.Points == 0 {
+		q.Points = DefaultPoints
+	}
+	return q
 }
 
 // Option is the JSON representation for options over the REST API
+// Position is the index of the option within its question, it is ignored in requests
+// CreatedAt and UpdatedAt are maintained by the storage, UpdatedAt only changes if the option itself changes
 type Option struct {
-	ID         int    `json:"id"`
-	Body       string `json:"body"`
-	Correct    bool   `json:"correct"`
-	QuestionID int    `json:"-"`
+	ID         int       `json:"id"`
+	Body       string    `json:"body"`
+	Correct    bool      `json:"correct"`
+	Position   int       `json:"position"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	QuestionID int       `json:"-"`
+}
+
+// ReorderRequest is the JSON representation for reordering the options of a question
+type ReorderRequest struct {
+	OptionIDs []int `json:"option_ids"`
 }
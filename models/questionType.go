@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Question types, questions without a type are DefaultQuestionType
+const (
+	// QuestionTypeSingleChoice has options of which exactly one is correct
+	QuestionTypeSingleChoice = "single_choice"
+	// QuestionTypeMultipleChoice has options of which at least one is correct
+	QuestionTypeMultipleChoice = "multiple_choice"
+	// QuestionTypeTrueFalse has exactly two options of which one is correct
+	QuestionTypeTrueFalse = "true_false"
+	// QuestionTypeFreeText has no options but AcceptedAnswers
+	QuestionTypeFreeText = "free_text"
+	// QuestionTypeNumeric has no options but a NumericAnswer
+	QuestionTypeNumeric = "numeric"
+
+	DefaultQuestionType = QuestionTypeMultipleChoice
+)
+
+// QuestionTypes are all known question types
+var QuestionTypes = []string{
+	QuestionTypeSingleChoice,
+	QuestionTypeMultipleChoice,
+	QuestionTypeTrueFalse,
+	QuestionTypeFreeText,
+	QuestionTypeNumeric,
+}
+
+// IsQuestionType checks if questionType is one of QuestionTypes
+func IsQuestionType(questionType string) bool {
+	for _, known := range QuestionTypes {
+		if questionType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOptions checks if questions of questionType are answered by selecting options
+func HasOptions(questionType string) bool {
+	switch questionType {
+	case QuestionTypeSingleChoice, QuestionTypeMultipleChoice, QuestionTypeTrueFalse:
+		return true
+	}
+	return false
+}
+
+// AcceptedAnswer is a correct answer to a free text question
+// Answers are compared after collapsing whitespace and, unless CaseSensitive is set, ignoring case
+// If Regex is set Answer is a regular expression that has to match the whole answer
+type AcceptedAnswer struct {
+	Answer        string `json:"answer"`
+	Regex         bool   `json:"regex"`
+	CaseSensitive bool   `json:"case_sensitive"`
+}
+
+// NormalizeAnswer trims answer and collapses all whitespace within it to single spaces
+func NormalizeAnswer(answer string) string {
+	return strings.Join(strings.Fields(answer), " ")
+}
+
+// compile returns the regular expression of a Regex answer
+func (a AcceptedAnswer) compile() (*regexp.Regexp, error) {
+	flags := "(?i)"
+	if a.CaseSensitive {
+		flags = ""
+	}
+	return regexp.Compile(flags + "^(?:" + a.Answer + ")$")
+}
+
+// Matches checks if answer is accepted
+// Invalid regular expressions never match, they are rejected by Validate
+func (a AcceptedAnswer) Matches(answer string) bool {
+	answer = NormalizeAnswer(answer)
+	if a.Regex {
+		re, err := a.compile()
+		return err == nil && re.MatchString(answer)
+	}
+	if a.CaseSensitive {
+		return answer == NormalizeAnswer(a.Answer)
+	}
+	return strings.EqualFold(answer, NormalizeAnswer(a.Answer))
+}
+
+// NumericAnswer is the correct answer to a numeric question, answers within Tolerance of Value are accepted
+type NumericAnswer struct {
+	Value     float64 `json:"value"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+// Matches checks if answer is within the tolerance of the value
+func (n NumericAnswer) Matches(answer float64) bool {
+	difference := answer - n.Value
+	if difference < 0 {
+		difference = -difference
+	}
+	return difference <= n.Tolerance
+}
+
+// validateType checks the answer key of the question against the rules of its type
+func (q Question) validateType() ValidationErrors {
+	var errs ValidationErrors
+	if !IsQuestionType(q.Type) {
+		return ValidationErrors{{Field: "type", Message: "must be one of " + strings.Join(QuestionTypes, ", ")}}
+	}
+	if HasOptions(q.Type) {
+		errs = append(errs, q.validateOptions()...)
+	} else if len(q.Options) > 0 {
+		errs = append(errs, ValidationError{Field: "options", Message: fmt.Sprintf("must be empty for type %s", q.Type)})
+	}
+	if q.Type == QuestionTypeFreeText {
+		if len(q.AcceptedAnswers) == 0 {
+			errs = append(errs, ValidationError{Field: "accepted_answers", Message: "must contain at least one answer"})
+		}
+		for i, answer := range q.AcceptedAnswers {
+			field := fmt.Sprintf("accepted_answers[%d].answer", i)
+			if NormalizeAnswer(answer.Answer) == "" {
+				errs = append(errs, ValidationError{Field: field, Message: "must not be empty"})
+			} else if _, err := answer.compile(); answer.Regex && err != nil {
+				errs = append(errs, ValidationError{Field: field, Message: "must be a valid regular expression"})
+			}
+		}
+	} else if len(q.AcceptedAnswers) > 0 {
+		errs = append(errs, ValidationError{Field: "accepted_answers", Message: fmt.Sprintf("must be empty for type %s", q.Type)})
+	}
+	if q.Type == QuestionTypeNumeric {
+		if q.NumericAnswer == nil {
+			errs = append(errs, ValidationError{Field: "numeric_answer", Message: "must be set"})
+		} else if q.NumericAnswer.Tolerance < 0 {
+			errs = append(errs, ValidationError{Field: "numeric_answer.tolerance", Message: "must not be negative"})
+		}
+	} else if q.NumericAnswer != nil {
+		errs = append(errs, ValidationError{Field: "numeric_answer", Message: fmt.Sprintf("must be empty for type %s", q.Type)})
+	}
+	return errs
+}
+
+// validateOptions checks the options of a choice question
+// Every type needs MinOptions options with a body, true/false exactly two, and at least or exactly one correct option
+func (q Question) validateOptions() ValidationErrors {
+	var errs ValidationErrors
+	if q.Type == QuestionTypeTrueFalse && len(q.Options) != 2 {
+		errs = append(errs, ValidationError{Field: "options", Message: "must contain exactly 2 options"})
+	} else if len(q.Options) < MinOptions {
+		errs = append(errs, ValidationError{
+			Field:   "options",
+			Message: fmt.Sprintf("must contain at least %d options", MinOptions),
+		})
+	}
+	correct := 0
+	for i, option := range q.Options {
+		if strings.TrimSpace(option.Body) == "" {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("options[%d].body", i), Message: "must not be empty"})
+		}
+		if option.Correct {
+			correct++
+		}
+	}
+	if q.Type == QuestionTypeMultipleChoice && correct == 0 {
+		errs = append(errs, ValidationError{Field: "options", Message: "must contain at least one correct option"})
+	} else if q.Type != QuestionTypeMultipleChoice && correct != 1 {
+		errs = append(errs, ValidationError{Field: "options", Message: "must contain exactly one correct option"})
+	}
+	return errs
+}
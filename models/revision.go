@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Revision is the JSON representation of an immutable snapshot of a question
+// Every change of a question stores a new revision, Number counts up from 1 per question
+// AuthorID is the user who made the change
+type Revision struct {
+	Number     int       `json:"number"`
+	QuestionID int       `json:"question_id"`
+	AuthorID   int       `json:"author_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Question   Question  `json:"question"`
+}
+
+// FieldChange is the JSON representation of a single field that differs between two revisions
+// From is null for added options and To is null for removed ones
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RevisionDiff is the JSON representation of every change from one revision to another
+type RevisionDiff struct {
+	QuestionID int           `json:"question_id"`
+	From       int           `json:"from"`
+	To         int           `json:"to"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+// Diff returns the changes from r to other
+func (r Revision) Diff(other Revision) RevisionDiff {
+	return RevisionDiff{
+		QuestionID: r.QuestionID,
+		From:       r.Number,
+		To:         other.Number,
+		Changes:    DiffQuestions(r.Question, other.Question),
+	}
+}
+
+// DiffQuestions returns every field that differs between from and to
+// Options are matched by ID, their fields are reported as options.{id}.body, options.{id}.correct and
+// options.{id}.position, added and removed options as options.{id}
+func DiffQuestions(from, to Question) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, FieldChange{Field: field, From: a, To: b})
+		}
+	}
+	add("body", from.Body, to.Body)
+	add("type", from.Type, to.Type)
+	add("shuffle_options", from.ShuffleOptions, to.ShuffleOptions)
+	add("difficulty", from.Difficulty, to.Difficulty)
+	add("time_limit", from.TimeLimit, to.TimeLimit)
+	add("points", from.Points, to.Points)
+	add("explanation", from.Explanation, to.Explanation)
+	add("tags", append([]string{}, from.Tags...), append([]string{}, to.Tags...))
+	add("accepted_answers", append([]AcceptedAnswer{}, from.AcceptedAnswers...), append([]AcceptedAnswer{}, to.AcceptedAnswers...))
+	add("numeric_answer", from.NumericAnswer, to.NumericAnswer)
+
+	remaining := make(map[int]Option, len(to.Options))
+	for _, option := range to.Options {
+		remaining[option.ID] = option
+	}
+	for _, option := range from.Options {
+		field := fmt.Sprintf("options.%d", option.ID)
+		other, ok := remaining[option.ID]
+		if !ok {
+			changes = append(changes, FieldChange{Field: field, From: option})
+			continue
+		}
+		delete(remaining, option.ID)
+		add(field+".body", option.Body, other.Body)
+		add(field+".correct", option.Correct, other.Correct)
+		add(field+".position", option.Position, other.Position)
+	}
+	for _, option := range to.Options {
+		if _, ok := remaining[option.ID]; ok {
+			changes = append(changes, FieldChange{Field: fmt.Sprintf("options.%d", option.ID), To: option})
+		}
+	}
+	return changes
+}
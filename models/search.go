@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptySearch is returned when a search query doesn't contain a single word
+var ErrEmptySearch = errors.New("q must contain at least one word")
+
+// SearchQuery is a parsed full-text search, a question matches if it matches every term
+type SearchQuery struct {
+	Terms []SearchTerm
+}
+
+// SearchTerm is a single word or a phrase of consecutive words
+// If Prefix is set the last word only has to match the beginning of a word
+type SearchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+// ParseSearchQuery parses q into a SearchQuery
+// Words are separated by whitespace, "quoted words" are phrases and a trailing * turns a word or phrase into a prefix
+// Words only consist of letters and digits and are lower case, everything else separates words
+func ParseSearchQuery(q string) (SearchQuery, error) {
+	var query SearchQuery
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		var chunk string
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				chunk, q = q[1:], ""
+			} else {
+				chunk, q = q[1:end+1], q[end+2:]
+				// "phrase"* is a prefix phrase just like "phrase*"
+				if strings.HasPrefix(q, "*") {
+					chunk, q = chunk+"*", q[1:]
+				}
+			}
+		} else {
+			end := strings.IndexFunc(q, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(q)
+			}
+			chunk, q = q[:end], q[end:]
+		}
+		term := SearchTerm{Words: SearchWords(chunk), Prefix: strings.HasSuffix(strings.TrimSpace(chunk), "*")}
+		if len(term.Words) > 0 {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+	if len(query.Terms) == 0 {
+		return query, ErrEmptySearch
+	}
+	return query, nil
+}
+
+// SearchWords splits text into lower case words of letters and digits
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Roles of users on a question, each role includes the permissions of the ones before it
+const (
+	// RoleViewer can read the question, its revisions and its collaborators and use it in tests
+	RoleViewer = "viewer"
+	// RoleEditor can change the question and its options and restore revisions
+	RoleEditor = "editor"
+	// RoleOwner can delete and restore the question and manage who it is shared with
+	RoleOwner = "owner"
+)
+
+// roleRanks orders the roles by their permissions
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// IsRole checks if role is one of RoleViewer, RoleEditor or RoleOwner
+func IsRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows checks if role has at least the permissions of required, an empty role allows nothing
+func RoleAllows(role, required string) bool {
+	return IsRole(role) && roleRanks[role] >= roleRanks[required]
+}
+
+// Share is the JSON representation of the role a user has on a question
+// The user who created a question is always its owner and isn't listed as a share
+type Share struct {
+	QuestionID int       `json:"question_id"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShareRequest is the JSON representation for sharing a question with a user or changing their role
+type ShareRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// Validate checks that the request names a user and a valid role
+func (s ShareRequest) Validate() error {
+	var errs ValidationErrors
+	if s.Username == "" {
+		errs = append(errs, ValidationError{Field: "username", Message: "must not be empty"})
+	}
+	if !IsRole(s.Role) {
+		errs = append(errs, ValidationError{
+			Field:   "role",
+			Message: fmt.Sprintf("must be one of %s, %s or %s", RoleViewer, RoleEditor, RoleOwner),
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxTagLength is the maximum number of characters of a tag name
+const MaxTagLength = 50
+
+// Tag filter modes for listing questions
+const (
+	// TagModeAll only lists questions that have every requested tag
+	TagModeAll = "all"
+	// TagModeAny lists questions that have at least one of the requested tags
+	TagModeAny = "any"
+)
+
+// Tag is the JSON representation for tags over the REST API
+// Tags belong to a user, QuestionCount is the number of their questions with the tag and ignored in requests
+type Tag struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	QuestionCount int    `json:"question_count"`
+}
+
+// NormalizeTag returns the canonical form of a tag name, tags are case-insensitive
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTags returns the canonical form of names sorted and without duplicates
+func NormalizeTags(names []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, name := range names {
+		name = NormalizeTag(name)
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// validateTagName returns a message if name isn't a valid normalized tag name
+// Tag names consist of letters, digits and - _ . + # so tags like c++ or c# are possible
+func validateTagName(name string) string {
+	if name == "" {
+		return "must not be empty"
+	}
+	if len([]rune(name)) > MaxTagLength {
+		return fmt.Sprintf("must not be longer than %d characters", MaxTagLength)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.+#", r) {
+			return "may only contain letters, digits and - _ . + #"
+		}
+	}
+	return ""
+}
+
+// Validate checks that the normalized name of the tag is valid
+func (t Tag) Validate() error {
+	if message := validateTagName(NormalizeTag(t.Name)); message != "" {
+		return ValidationErrors{{Field: "name", Message: message}}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits of tests
+const (
+	MaxTestTitleLength       = 200
+	MaxTestDescriptionLength = 5000
+	// MaxTestTimeLimit is the longest time limit of a test in seconds
+	MaxTestTimeLimit = 4 * 3600
+)
+
+// Test is the JSON representation for tests over the REST API
+// A test groups questions of its author into an assessment, QuestionIDs are kept in order
+// TimeLimit is in seconds, 0 means no limit, PassThreshold is the percentage of points needed to pass
+type Test struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	TimeLimit     int    `json:"time_limit"`
+	PassThreshold int    `json:"pass_threshold"`
+	QuestionIDs   []int  `json:"question_ids"`
+}
+
+// TestQuestionsRequest is the JSON representation for adding questions to a test
+type TestQuestionsRequest struct {
+	QuestionIDs []int `json:"question_ids"`
+}
+
+// Validate checks that the test has a title, valid limits and contains every question only once
+// It returns nil or ValidationErrors listing every violation
+func (t Test) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(t.Title) == "" {
+		errs = append(errs, ValidationError{Field: "title", Message: "must not be empty"})
+	} else if len([]rune(t.Title)) > MaxTestTitleLength {
+		errs = append(errs, ValidationError{
+			Field:   "title",
+			Message: fmt.Sprintf("must not be longer than %d characters", MaxTestTitleLength),
+		})
+	}
+	if len([]rune(t.Description)) > MaxTestDescriptionLength {
+		errs = append(errs, ValidationError{
+			Field:   "description",
+			Message: fmt.Sprintf("must not be longer than %d characters", MaxTestDescriptionLength),
+		})
+	}
+	if t.TimeLimit < 0 || t.TimeLimit > MaxTestTimeLimit {
+		errs = append(errs, ValidationError{
+			Field:   "time_limit",
+			Message: fmt.Sprintf("must be between 0 and %d seconds", MaxTestTimeLimit),
+		})
+	}
+	if t.PassThreshold < 0 || t.PassThreshold > 100 {
+		errs = append(errs, ValidationError{Field: "pass_threshold", Message: "must be between 0 and 100"})
+	}
+	seen := make(map[int]bool, len(t.QuestionIDs))
+	for i, id := range t.QuestionIDs {
+		if seen[id] {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("question_ids[%d]", i), Message: "must be unique"})
+		}
+		seen[id] = true
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -1,21 +1,37 @@
 package models
 
+import "time"
+
 // User is the JSON representation for users over the REST API
+// CreatedAt and UpdatedAt are maintained by the storage, rehashing a password on login doesn't count as an update
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 // UserResponse is the JSON representation of user without password, for API response
 type UserResponse struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // JWTTokenResponse is the JSON representation for created JWT tokens
+// Token is the short-lived access token, RefreshToken can be exchanged for a new pair
 type JWTTokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenRequest is the JSON representation for refresh and logout requests
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type key int
@@ -23,4 +39,14 @@ type key int
 const (
 	// ContextUserID is the key used for passing the userID between JWTMiddleware and http handlers
 	ContextUserID key = iota
+	// ContextTokenID is the key used for passing the jti of the access token between JWTMiddleware and http handlers
+	ContextTokenID
+	// ContextTokenExpiry is the key used for passing the expiry of the access token between JWTMiddleware and http handlers
+	ContextTokenExpiry
+	// ContextRequestID is the key used for passing the request id from RequestIDMiddleware to everything after it
+	ContextRequestID
+	// ContextDeliveryScope is the key used for passing the DeliveryScope of a delivery token to http handlers
+	ContextDeliveryScope
+	// ContextAttemptID is the key used for passing the attempt ID of an attempt token to http handlers
+	ContextAttemptID
 )
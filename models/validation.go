@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MinOptions is the minimum number of options a question needs
+const MinOptions = 2
+
+// ValidationError is the JSON representation of a single field-level violation
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is returned when a question violates the question invariants
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Field + " " + e.Message
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// Validate checks that the question has a body, a valid answer key for its type, valid tags and valid scoring metadata
+// It returns nil or ValidationErrors listing every violation
+func (q Question) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(q.Body) == "" {
+		errs = append(errs, ValidationError{Field: "body", Message: "must not be empty"})
+	}
+	errs = append(errs, q.validateType()...)
+	for i, tag := range q.Tags {
+		if message := validateTagName(NormalizeTag(tag)); message != "" {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("tags[%d]", i), Message: message})
+		}
+	}
+	errs = append(errs, q.validateMetadata()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateMetadata checks difficulty, time limit, points and explanation of the question
+func (q Question) validateMetadata() ValidationErrors {
+	var errs ValidationErrors
+	if !IsDifficulty(q.Difficulty) {
+		errs = append(errs, ValidationError{
+			Field:   "difficulty",
+			Message: fmt.Sprintf("must be one of %s, %s or %s", DifficultyEasy, DifficultyMedium, DifficultyHard),
+		})
+	}
+	if q.TimeLimit < 0 || q.TimeLimit > MaxTimeLimit {
+		errs = append(errs, ValidationError{
+			Field:   "time_limit",
+			Message: fmt.Sprintf("must be between 0 and %d seconds", MaxTimeLimit),
+		})
+	}
+	if q.Points < 1 || q.Points > MaxPoints {
+		errs = append(errs, ValidationError{Field: "points", Message: fmt.Sprintf("must be between 1 and %d", MaxPoints)})
+	}
+	if len([]rune(q.Explanation)) > MaxExplanationLength {
+		errs = append(errs, ValidationError{
+			Field:   "explanation",
+			Message: fmt.Sprintf("must not be longer than %d characters", MaxExplanationLength),
+		})
+	}
+	return errs
+}
+
+// IsDifficulty checks if difficulty is one of the known difficulties
+func IsDifficulty(difficulty string) bool {
+	switch difficulty {
+	case DifficultyEasy, DifficultyMedium, DifficultyHard:
+		return true
+	}
+	return false
+}
+
+// ValidateReplacement checks that every option ID in replacement belongs to q and is used only once
+// Options without an ID are new and always valid
+func (q Question) ValidateReplacement(replacement Question) error {
+	var errs ValidationErrors
+	existing := make(map[int]bool)
+	for _, option := range q.Options {
+		existing[option.ID] = true
+	}
+	seen := make(map[int]bool)
+	for i, option := range replacement.Options {
+		if option.ID == 0 {
+			continue
+		}
+		field := fmt.Sprintf("options[%d].id", i)
+		if !existing[option.ID] {
+			errs = append(errs, ValidationError{Field: field, Message: "does not belong to this question"})
+		} else if seen[option.ID] {
+			errs = append(errs, ValidationError{Field: field, Message: "must be unique"})
+		}
+		seen[option.ID] = true
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateReorder checks that optionIDs contains every option of q exactly once
+func (q Question) ValidateReorder(optionIDs []int) error {
+	if len(optionIDs) != len(q.Options) {
+		return ValidationErrors{{Field: "option_ids", Message: "must contain every option of the question"}}
+	}
+	remaining := make(map[int]bool)
+	for _, option := range q.Options {
+		remaining[option.ID] = true
+	}
+	for i, optionID := range optionIDs {
+		if !remaining[optionID] {
+			return ValidationErrors{{
+				Field:   fmt.Sprintf("option_ids[%d]", i),
+				Message: "must be an option of the question and appear only once",
+			}}
+		}
+		delete(remaining, optionID)
+	}
+	return nil
+}
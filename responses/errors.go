@@ -0,0 +1,104 @@
+package responses
+
+import (
+	"errors"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/storage"
+	"log"
+	"net/http"
+)
+
+// Error codes returned in models.ErrorResponse, clients can branch on these
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodePreconditionNeeded = "precondition_required"
+	CodeAttemptClosed      = "attempt_closed"
+	CodeNoQuestionsLeft    = "no_questions_left"
+	CodeValidationFailed   = "validation_failed"
+	CodeInternal           = "internal_error"
+)
+
+// APIError is an error that carries the status and code it should be reported with
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// BadRequest wraps err, usually a decoding or parsing error, into a 400 APIError
+func BadRequest(err error) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error()}
+}
+
+// ErrUnauthorized is reported when a request is missing valid authentication
+var ErrUnauthorized = &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: "unauthorized"}
+
+// ErrPreconditionRequired is reported when a change is missing the If-Match header although it is required
+var ErrPreconditionRequired = &APIError{
+	Status:  http.StatusPreconditionRequired,
+	Code:    CodePreconditionNeeded,
+	Message: "If-Match header is required",
+}
+
+// sentinels maps the storage errors to their status and code
+var sentinels = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{storage.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{storage.ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{storage.ErrConflict, http.StatusConflict, CodeConflict},
+	{storage.ErrInvalidCredentials, http.StatusUnauthorized, CodeInvalidCredentials},
+	{storage.ErrInvalidToken, http.StatusUnauthorized, CodeInvalidToken},
+	{storage.ErrVersionMismatch, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{storage.ErrAttemptClosed, http.StatusConflict, CodeAttemptClosed},
+	{models.ErrNoQuestionsLeft, http.StatusConflict, CodeNoQuestionsLeft},
+}
+
+// Error writes err as models.ErrorResponse
+// APIError, models.ValidationErrors and the storage sentinel errors are mapped to their status and code,
+// anything else is logged and reported as an internal error without leaking details
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	response := models.ErrorResponse{RequestID: RequestID(r)}
+	status := http.StatusInternalServerError
+
+	var apiError *APIError
+	var validationErrors models.ValidationErrors
+	switch {
+	case errors.As(err, &apiError):
+		status, response.Code, response.Message = apiError.Status, apiError.Code, apiError.Message
+	case errors.As(err, &validationErrors):
+		status, response.Code, response.Message = http.StatusUnprocessableEntity, CodeValidationFailed, "validation failed"
+		response.Details = validationErrors
+	default:
+		response.Code, response.Message = CodeInternal, "internal server error"
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel.err) {
+				status, response.Code, response.Message = sentinel.status, sentinel.code, err.Error()
+				break
+			}
+		}
+	}
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s %s: %v", response.RequestID, r.Method, r.RequestURI, err)
+	}
+	JSON(w, status, response)
+}
+
+// RequestID returns the request id set by middlewares.RequestIDMiddleware or an empty string
+func RequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(models.ContextRequestID).(string)
+	return requestID
+}
@@ -0,0 +1,17 @@
+package responses
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// JSON writes payload encoded as JSON with statusCode
+func JSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(payload)
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"time"
+)
+
+// newAttempt returns a new attempt of userID for request, it is not stored yet
+// Questions of tests and question IDs are checked through s, so they have to belong to userID
+func newAttempt(s Storage, userID int, request models.StartAttemptRequest) (models.Attempt, error) {
+	err := request.Validate()
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	attempt := models.Attempt{
+		Candidate:   request.Candidate,
+		Status:      models.AttemptInProgress,
+		QuestionIDs: request.QuestionIDs,
+		TimeLimit:   request.TimeLimit,
+		StartedAt:   timestamp(),
+		Answers:     []models.AttemptAnswer{},
+		AuthorID:    userID,
+	}
+	if request.TestID != 0 {
+		test, err := s.GetTest(request.TestID, userID)
+		if err != nil {
+			return models.Attempt{}, err
+		}
+		if len(test.QuestionIDs) == 0 {
+			return models.Attempt{}, models.ValidationErrors{{Field: "test_id", Message: "must have at least one question"}}
+		}
+		attempt.TestID, attempt.QuestionIDs = test.ID, test.QuestionIDs
+		attempt.TimeLimit, attempt.PassThreshold = test.TimeLimit, test.PassThreshold
+	}
+	for _, id := range attempt.QuestionIDs {
+		question, err := s.Get(id, userID)
+		if err != nil {
+			return models.Attempt{}, err
+		}
+		attempt.Points = append(attempt.Points, question.Points)
+		attempt.Questions = append(attempt.Questions, question)
+	}
+	if attempt.TimeLimit > 0 {
+		expiresAt := attempt.StartedAt.Add(time.Duration(attempt.TimeLimit) * time.Second)
+		attempt.ExpiresAt = &expiresAt
+	}
+	return attempt, nil
+}
+
+// gradeAnswer returns the graded answer to the current question of attempt, it is not stored yet
+// If the attempt is closed or request doesn't answer the current question it will result in an error
+func gradeAnswer(s Storage, attempt models.Attempt, request models.AnswerRequest) (models.AttemptAnswer, error) {
+	answeredAt := timestamp()
+	if attempt.Status != models.AttemptInProgress || attempt.Expired(answeredAt) {
+		return models.AttemptAnswer{}, fmt.Errorf("%w: attempt %d", ErrAttemptClosed, attempt.ID)
+	}
+	question, err := attempt.CurrentQuestion()
+	if err != nil {
+		return models.AttemptAnswer{}, err
+	}
+	if request.QuestionID != question.ID {
+		return models.AttemptAnswer{}, models.ValidationErrors{{
+			Field:   "question_id",
+			Message: fmt.Sprintf("must be the current question %d", question.ID),
+		}}
+	}
+	result, err := question.Check(request.CheckRequest)
+	if err != nil {
+		return models.AttemptAnswer{}, err
+	}
+	return models.AttemptAnswer{
+		QuestionID: question.ID,
+		OptionIDs:  request.OptionIDs,
+		Answer:     request.Answer,
+		Value:      request.Value,
+		AnsweredAt: answeredAt,
+		Correct:    result.Correct,
+		Points:     result.Score * float64(attempt.Points[len(attempt.Answers)]),
+	}, nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"errors"
+	"github.com/lib/pq"
+	"github.com/makupi/backend-homework/models"
+	"github.com/mattn/go-sqlite3"
+	"strconv"
+	"strings"
+)
+
+// dialect contains everything that differs between the databases supported by sqlStorage
+type dialect interface {
+	// rebind rewrites the ? placeholders of query to the placeholders of the database
+	rebind(query string) string
+	// insert runs an INSERT statement on q and returns the id of the new row
+	insert(q querier, query string, args ...interface{}) (int64, error)
+	// isUniqueViolation checks if err is caused by a UNIQUE constraint
+	isUniqueViolation(err error) bool
+	// lockRows returns the clause appended to SELECTs to lock the rows until the transaction ends
+	lockRows() string
+	// tableExists returns a query that counts the tables named like its only argument
+	tableExists() string
+	// matchQuery renders query in the full-text search syntax of the database
+	matchQuery(query models.SearchQuery) string
+	// searchFilter returns the condition on questions matching the rendered search query in its only placeholder
+	searchFilter() string
+	// searchRanks returns the ID and Rank of every question matching the rendered search query and conditions,
+	// conditions is a condition on questions with its own args
+	searchRanks(q querier, query, conditions string, args []interface{}) ([]models.Question, error)
+	// snippets returns a query for the snippets of n questions, its arguments are the rendered search query
+	// followed by the question IDs, it returns the question ID and its snippet
+	snippets(n int) string
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) insert(q querier, query string, args ...interface{}) (int64, error) {
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// lockRows is empty for SQLite, transactions are started with _txlock=immediate and hold the write lock already
+func (sqliteDialect) lockRows() string {
+	return ""
+}
+
+func (sqliteDialect) tableExists() string {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+}
+
+type postgresDialect struct{}
+
+// rebind replaces every ? with $1, $2, ...
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// insert uses RETURNING because lib/pq doesn't support LastInsertId
+func (postgresDialect) insert(q querier, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := q.QueryRow(query+` RETURNING id`, args...).Scan(&id)
+	return id, err
+}
+
+func (postgresDialect) isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (postgresDialect) lockRows() string {
+	return ` FOR UPDATE`
+}
+
+func (postgresDialect) tableExists() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+}
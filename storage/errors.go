@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when the requested entity doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned when the entity exists but the user has no access to it
+	ErrForbidden = errors.New("access denied")
+	// ErrConflict is returned when the entity conflicts with an existing one
+	ErrConflict = errors.New("already exists")
+	// ErrInvalidCredentials is returned when username and password don't match a user
+	ErrInvalidCredentials = errors.New("user does not exist or wrong password")
+	// ErrInvalidToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrVersionMismatch is returned when a question was changed since the version a change is based on
+	ErrVersionMismatch = errors.New("version does not match")
+	// ErrAttemptClosed is returned when an attempt that was submitted or ran out of time is changed
+	ErrAttemptClosed = errors.New("attempt is closed")
+)
+
+// notFound returns ErrNotFound wrapped with the kind and id of the missing entity
+func notFound(kind string, id int) error {
+	return fmt.Errorf("%s %d %w", kind, id, ErrNotFound)
+}
+
+// forbidden returns ErrForbidden wrapped with the kind and id of the entity
+func forbidden(kind string, id int) error {
+	return fmt.Errorf("%w to %s %d", ErrForbidden, kind, id)
+}
+
+// requiresRole returns ErrForbidden wrapped with the question id and the role the change requires
+func requiresRole(id int, role string) error {
+	return fmt.Errorf("%w to question %d, it requires the %s role", ErrForbidden, id, role)
+}
@@ -0,0 +1,45 @@
+package storage
+
+import "time"
+
+// SetNow replaces the clock of all Storage implementations until the returned function is called
+func SetNow(fn func() time.Time) (restore func()) {
+	previous := now
+	now = fn
+	return func() { now = previous }
+}
+
+// StoredPassword returns the password of userID as it is stored in s
+func StoredPassword(s Storage, userID int) (stored string, err error) {
+	switch s := s.(type) {
+	case *MemoryStorage:
+		s.read(func() {
+			stored = s.data.users[userID].Password
+		})
+	case *SqliteStorage:
+		err = s.db().QueryRow(`SELECT password FROM users WHERE id = (?)`, userID).Scan(&stored)
+	case *PostgresStorage:
+		err = s.db().QueryRow(`SELECT password FROM users WHERE id = (?)`, userID).Scan(&stored)
+	}
+	return
+}
+
+// SetStoredPassword replaces the stored password of userID with stored, e.g. a legacy plaintext password
+func SetStoredPassword(s Storage, userID int, stored string) error {
+	switch s := s.(type) {
+	case *MemoryStorage:
+		return s.atomic(func(s *MemoryStorage) error {
+			user := s.data.users[userID]
+			user.Password = stored
+			s.data.users[userID] = user
+			return nil
+		})
+	case *SqliteStorage:
+		_, err := s.db().Exec(`UPDATE users SET password = (?) WHERE id = (?)`, stored, userID)
+		return err
+	case *PostgresStorage:
+		_, err := s.db().Exec(`UPDATE users SET password = (?) WHERE id = (?)`, stored, userID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"sort"
+)
+
+// copyAttempt returns a copy of attempt that shares no slices with it
+func copyAttempt(attempt models.Attempt) models.Attempt {
+	attempt.QuestionIDs = append([]int{}, attempt.QuestionIDs...)
+	attempt.Points = append([]int{}, attempt.Points...)
+	attempt.Answers = append([]models.AttemptAnswer{}, attempt.Answers...)
+	questions := make([]models.Question, len(attempt.Questions))
+	for i, question := range attempt.Questions {
+		question = copyAnswers(question)
+		question.Options = append([]models.Option{}, question.Options...)
+		question.Tags = append([]string{}, question.Tags...)
+		questions[i] = question
+	}
+	attempt.Questions = questions
+	return attempt
+}
+
+// attempt returns a copy of the attempt id, if its time ran out it is closed as expired first
+func (s *MemoryStorage) attempt(id int) (attempt models.Attempt, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		stored, ok := s.data.attempts[id]
+		if !ok {
+			return notFound("attempt", id)
+		}
+		if stored.Expired(timestamp()) {
+			stored.Finish(models.AttemptExpired, *stored.ExpiresAt)
+			s.data.remember(s.data.attempts, id)
+			s.data.attempts[id] = stored
+		}
+		attempt = copyAttempt(stored)
+		return nil
+	})
+	return
+}
+
+// StartAttempt starts a new attempt of userID for a test or a set of questions
+// If the test or a question doesn't belong to userID it will result in an error
+func (s *MemoryStorage) StartAttempt(userID int, request models.StartAttemptRequest) (started models.Attempt, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		attempt, err := newAttempt(s, userID, request)
+		if err != nil {
+			return err
+		}
+		attempt.ID = s.data.nextID()
+		attempt.Calculate()
+		s.data.remember(s.data.attempts, attempt.ID)
+		s.data.attempts[attempt.ID] = attempt
+		started = copyAttempt(attempt)
+		return nil
+	})
+	return
+}
+
+// ListAttempts returns all attempts started by userID in the order they were started
+func (s *MemoryStorage) ListAttempts(userID int) (attempts []models.Attempt, err error) {
+	attempts = []models.Attempt{}
+	err = s.atomic(func(s *MemoryStorage) error {
+		for id, attempt := range s.data.attempts {
+			if attempt.AuthorID != userID {
+				continue
+			}
+			attempt, err := s.attempt(id)
+			if err != nil {
+				return err
+			}
+			attempts = append(attempts, attempt)
+		}
+		return nil
+	})
+	sort.Slice(attempts, func(i, j int) bool {
+		return attempts[i].ID < attempts[j].ID
+	})
+	return
+}
+
+// GetAttempt returns an attempt by ID, will only return attempts started by userID
+// If the attempt doesn't exist or wasn't started by userID it will result in ErrNotFound or ErrForbidden
+func (s *MemoryStorage) GetAttempt(id, userID int) (models.Attempt, error) {
+	attempt, err := s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	if attempt.AuthorID != userID {
+		return models.Attempt{}, forbidden("attempt", id)
+	}
+	return attempt, nil
+}
+
+// CandidateAttempt returns an attempt by ID for its candidate
+func (s *MemoryStorage) CandidateAttempt(id int) (models.Attempt, error) {
+	return s.attempt(id)
+}
+
+// AnswerAttempt records and grades the answer to the current question of the attempt id
+// If the attempt is closed it will result in ErrAttemptClosed
+func (s *MemoryStorage) AnswerAttempt(id int, request models.AnswerRequest) (attempt models.Attempt, err error) {
+	attempt, err = s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		attempt, err = s.attempt(id)
+		if err != nil {
+			return err
+		}
+		answer, err := gradeAnswer(s, attempt, request)
+		if err != nil {
+			return err
+		}
+		attempt.Answers = append(attempt.Answers, answer)
+		attempt.Calculate()
+		s.data.remember(s.data.attempts, id)
+		s.data.attempts[id] = copyAttempt(attempt)
+		return nil
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempt, nil
+}
+
+// SubmitAttempt closes the attempt id, questions that weren't answered get no points
+// If the attempt is already closed it will result in ErrAttemptClosed
+func (s *MemoryStorage) SubmitAttempt(id int) (attempt models.Attempt, err error) {
+	attempt, err = s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		attempt, err = s.attempt(id)
+		if err != nil {
+			return err
+		}
+		if attempt.Status != models.AttemptInProgress {
+			return fmt.Errorf("%w: attempt %d", ErrAttemptClosed, id)
+		}
+		attempt.Finish(models.AttemptSubmitted, timestamp())
+		s.data.remember(s.data.attempts, id)
+		s.data.attempts[id] = copyAttempt(attempt)
+		return nil
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempt, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import "github.com/makupi/backend-homework/models"
+
+// copyQuestion returns a copy of question that shares no slices or pointers with it
+func copyQuestion(question models.Question) models.Question {
+	question = copyAnswers(question)
+	question.Options = append([]models.Option{}, question.Options...)
+	question.Tags = append([]string{}, question.Tags...)
+	return question
+}
+
+// copyRevision returns a copy of revision that shares nothing with the stored one
+func copyRevision(revision models.Revision) models.Revision {
+	revision.Question = copyQuestion(revision.Question)
+	return revision
+}
+
+// saveRevision returns the question id and stores it as a new revision made by userID
+// The question may be in the trash, access has to be checked by the caller
+func (s *MemoryStorage) saveRevision(id, userID int) (models.Question, error) {
+	stored, ok := s.question(id)
+	if !ok {
+		return models.Question{}, notFound("question", id)
+	}
+	question := stored.Question
+	s.data.remember(s.data.revisions, id)
+	s.data.revisions[id] = append(s.data.revisions[id], models.Revision{
+		Number:     len(s.data.revisions[id]) + 1,
+		QuestionID: id,
+		AuthorID:   userID,
+		CreatedAt:  timestamp(),
+		Question:   copyQuestion(question),
+	})
+	return question, nil
+}
+
+// touchQuestions marks the questions ids as updated by userID, increments their version and stores a new revision of each
+func (s *MemoryStorage) touchQuestions(ids []int, userID int) error {
+	for _, id := range ids {
+		stored := s.data.questions[id]
+		stored.UpdatedAt = timestamp()
+		stored.UpdatedBy = userID
+		stored.Version++
+		s.data.remember(s.data.questions, id)
+		s.data.questions[id] = stored
+		_, err := s.saveRevision(id, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRevisions returns every revision of a question, oldest first
+// If the question doesn't belong to userID it will result in an error
+func (s *MemoryStorage) ListRevisions(questionID, userID int) (revisions []models.Revision, err error) {
+	s.read(func() {
+		err = s.checkQuestions(userID, []int{questionID})
+		if err != nil {
+			return
+		}
+		revisions = make([]models.Revision, len(s.data.revisions[questionID]))
+		for i, revision := range s.data.revisions[questionID] {
+			revisions[i] = copyRevision(revision)
+		}
+	})
+	return
+}
+
+// GetRevision returns a single revision of a question
+// If the question doesn't belong to userID or the revision doesn't exist it will result in an error
+func (s *MemoryStorage) GetRevision(questionID, number, userID int) (revision models.Revision, err error) {
+	s.read(func() {
+		err = s.checkQuestions(userID, []int{questionID})
+		if err != nil {
+			return
+		}
+		revisions := s.data.revisions[questionID]
+		if number < 1 || number > len(revisions) {
+			err = notFound("revision", number)
+			return
+		}
+		revision = copyRevision(revisions[number-1])
+	})
+	return
+}
+
+// RestoreRevision replaces a question with the snapshot of one of its revisions, storing a new revision
+// If the question doesn't belong to userID or the revision doesn't exist it will result in an error
+func (s *MemoryStorage) RestoreRevision(questionID, number, userID int) (restored models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		restored, err = restoreRevision(s, questionID, number, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return restored, nil
+}
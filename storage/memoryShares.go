@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"sort"
+)
+
+// ListShares returns the users questionID is shared with sorted by username
+// If userID has no role on the question it will result in an error
+func (s *MemoryStorage) ListShares(questionID, userID int) (shares []models.Share, err error) {
+	s.read(func() {
+		err = s.checkQuestions(userID, []int{questionID})
+		if err != nil {
+			return
+		}
+		shares = []models.Share{}
+		for _, share := range s.data.shares[questionID] {
+			share.Username = s.data.users[share.UserID].Username
+			shares = append(shares, share)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].Username < shares[j].Username
+	})
+	return shares, nil
+}
+
+// ShareQuestion grants the user request.Username request.Role on questionID, replacing the role they had before
+// If userID isn't an owner of the question, the user doesn't exist or created the question it will result in an error
+func (s *MemoryStorage) ShareQuestion(questionID, userID int, request models.ShareRequest) (share models.Share, err error) {
+	err = request.Validate()
+	if err != nil {
+		return models.Share{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		_, err := s.get(questionID, userID, models.RoleOwner, false)
+		if err != nil {
+			return err
+		}
+		user, ok := s.userByName(request.Username)
+		if !ok {
+			return fmt.Errorf("user %q %w", request.Username, ErrNotFound)
+		}
+		if user.ID == s.data.questions[questionID].userID {
+			return fmt.Errorf("user %q as owner of question %d %w", request.Username, questionID, ErrConflict)
+		}
+		if s.data.shares[questionID] == nil {
+			s.data.remember(s.data.shares, questionID)
+			s.data.shares[questionID] = make(map[int]models.Share)
+		}
+		share, ok = s.data.shares[questionID][user.ID]
+		if !ok {
+			share = models.Share{QuestionID: questionID, UserID: user.ID, CreatedAt: timestamp()}
+		}
+		share.Role = request.Role
+		s.data.remember(s.data.shares[questionID], user.ID)
+		s.data.shares[questionID][user.ID] = share
+		share.Username = user.Username
+		return nil
+	})
+	if err != nil {
+		return models.Share{}, err
+	}
+	return share, nil
+}
+
+// RevokeShare removes the role of sharedUserID on questionID and removes the question from their tests
+// Owners can revoke every share, everyone else only their own
+// If the question isn't shared with sharedUserID it will result in ErrNotFound
+func (s *MemoryStorage) RevokeShare(questionID, sharedUserID, userID int) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		role := models.RoleOwner
+		if sharedUserID == userID {
+			role = models.RoleViewer
+		}
+		_, err := s.get(questionID, userID, role, false)
+		if err != nil {
+			return err
+		}
+		if _, ok := s.data.shares[questionID][sharedUserID]; !ok {
+			return fmt.Errorf("share of question %d with user %d %w", questionID, sharedUserID, ErrNotFound)
+		}
+		s.data.remember(s.data.shares[questionID], sharedUserID)
+		delete(s.data.shares[questionID], sharedUserID)
+		for id, test := range s.data.tests {
+			if test.userID == sharedUserID {
+				test.QuestionIDs, _ = removeID(test.QuestionIDs, questionID)
+				s.data.remember(s.data.tests, id)
+				s.data.tests[id] = test
+			}
+		}
+		return nil
+	})
+}
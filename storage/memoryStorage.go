@@ -0,0 +1,724 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps everything in memory, all data is lost when the app stops
+// It is safe for concurrent use, every call holds a single lock for its whole duration
+type MemoryStorage struct {
+	Passwords PasswordHasher
+	mu        *sync.Mutex
+	data      *memoryData
+	// locked is only set for the MemoryStorage passed to Atomic, which already holds mu
+	locked bool
+}
+
+// memoryData holds the state of a MemoryStorage, the equivalent of the tables of the SQL databases
+type memoryData struct {
+	users         map[int]models.User
+	questions     map[int]memoryQuestion
+	tags          map[int]memoryTag
+	tests         map[int]memoryTest
+	attempts      map[int]models.Attempt
+	revisions     map[int][]models.Revision
+	shares        map[int]map[int]models.Share
+	refreshTokens map[string]memoryRefreshToken
+	revokedTokens map[string]time.Time
+	lastID        int
+	// undo reverts the changes of the running unit of work, it is nil outside of one
+	undo []func()
+}
+
+// memoryQuestion is a question with the user it belongs to, Options are kept in order
+// Tags are stored as tagIDs so renaming a tag renames it everywhere
+type memoryQuestion struct {
+	models.Question
+	userID int
+	tagIDs []int
+}
+
+// memoryRefreshToken is a refresh token stored by its hash
+type memoryRefreshToken struct {
+	userID    int
+	expiresAt time.Time
+	revoked   bool
+}
+
+// NewMemoryStorage returns an empty MemoryStorage, passwords are hashed with passwords
+func NewMemoryStorage(passwords PasswordHasher) *MemoryStorage {
+	return &MemoryStorage{
+		Passwords: passwords,
+		mu:        &sync.Mutex{},
+		data: &memoryData{
+			users:         make(map[int]models.User),
+			questions:     make(map[int]memoryQuestion),
+			tags:          make(map[int]memoryTag),
+			tests:         make(map[int]memoryTest),
+			attempts:      make(map[int]models.Attempt),
+			revisions:     make(map[int][]models.Revision),
+			shares:        make(map[int]map[int]models.Share),
+			refreshTokens: make(map[string]memoryRefreshToken),
+			revokedTokens: make(map[string]time.Time),
+		},
+	}
+}
+
+// remember logs the current entry of key in table, one of the maps of d, so rollback can restore it
+// It has to be called before every change of an entry, changes outside of a unit of work are not logged
+func (d *memoryData) remember(table, key interface{}) {
+	if d.undo == nil {
+		return
+	}
+	m, k := reflect.ValueOf(table), reflect.ValueOf(key)
+	// the zero Value of a missing entry deletes key again
+	previous := m.MapIndex(k)
+	d.undo = append(d.undo, func() { m.SetMapIndex(k, previous) })
+}
+
+// rollback reverts every change logged since the unit of work started, newest first
+func (d *memoryData) rollback() {
+	for i := len(d.undo) - 1; i >= 0; i-- {
+		d.undo[i]()
+	}
+}
+
+// nextID returns a new ID, IDs are unique across all entities
+func (d *memoryData) nextID() int {
+	if d.undo != nil {
+		lastID := d.lastID
+		d.undo = append(d.undo, func() { d.lastID = lastID })
+	}
+	d.lastID++
+	return d.lastID
+}
+
+// Atomic runs fn while holding the lock, fn must only use the Storage it is given
+// If fn returns an error or panics all changes made by fn are reverted
+// Calling Atomic within fn reuses the running unit of work
+func (s *MemoryStorage) Atomic(fn func(tx Storage) error) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		return fn(s)
+	})
+}
+
+func (s *MemoryStorage) atomic(fn func(s *MemoryStorage) error) error {
+	if s.locked {
+		return fn(s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.undo = []func(){}
+	restore := true
+	defer func() {
+		if restore {
+			s.data.rollback()
+		}
+		s.data.undo = nil
+	}()
+	err := fn(&MemoryStorage{Passwords: s.Passwords, mu: s.mu, data: s.data, locked: true})
+	restore = err != nil
+	return err
+}
+
+// read runs fn while holding the lock without reverting anything
+func (s *MemoryStorage) read(fn func()) {
+	if !s.locked {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	fn()
+}
+
+// question returns a copy of the stored question id with the positions of its options and its tags set
+func (s *MemoryStorage) question(id int) (memoryQuestion, bool) {
+	question, ok := s.data.questions[id]
+	if !ok {
+		return question, false
+	}
+	question.Options = append([]models.Option{}, question.Options...)
+	question.Question = copyAnswers(question.Question)
+	for i := range question.Options {
+		question.Options[i].Position = i
+	}
+	question.Tags = s.tagNames(question.tagIDs)
+	return question, true
+}
+
+// copyAnswers returns question with copies of its accepted answers and numeric answer
+// so the stored question can't be changed through the returned one or the other way round
+func copyAnswers(question models.Question) models.Question {
+	question.AcceptedAnswers = append([]models.AcceptedAnswer(nil), question.AcceptedAnswers...)
+	if question.NumericAnswer != nil {
+		numeric := *question.NumericAnswer
+		question.NumericAnswer = &numeric
+	}
+	return question
+}
+
+// List returns the requested page of the questions userID has a role on
+// Searches only include matching questions and set their snippet, tags filter according to page.TagMode
+func (s *MemoryStorage) List(userID int, page models.PageRequest) (result models.QuestionPage, err error) {
+	s.read(func() {
+		var questions []models.Question
+		for id, stored := range s.data.questions {
+			if s.role(stored, userID) == "" || stored.DeletedAt != nil {
+				continue
+			}
+			question, _ := s.question(id)
+			if !hasTags(question.Tags, page) || !hasMetadata(question.Question, page) {
+				continue
+			}
+			if page.Query != nil {
+				var ok bool
+				question.Rank, question.Snippet, ok = memoryMatch(question.Question, *page.Query)
+				if !ok {
+					continue
+				}
+			}
+			questions = append(questions, question.Question)
+		}
+		result = models.NewQuestionPage(page, fetchPage(questions, page), len(questions))
+	})
+	return
+}
+
+// hasMetadata checks if question matches the difficulties, points, time limit and last change filter of page
+func hasMetadata(question models.Question, page models.PageRequest) bool {
+	if len(page.Difficulties) > 0 {
+		found := false
+		for _, difficulty := range page.Difficulties {
+			found = found || question.Difficulty == difficulty
+		}
+		if !found {
+			return false
+		}
+	}
+	if page.MinPoints > 0 && question.Points < page.MinPoints {
+		return false
+	}
+	if page.MaxPoints > 0 && question.Points > page.MaxPoints {
+		return false
+	}
+	if page.MaxTimeLimit > 0 && (question.TimeLimit < 1 || question.TimeLimit > page.MaxTimeLimit) {
+		return false
+	}
+	if !page.UpdatedSince.IsZero() && question.UpdatedAt.Before(page.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+// Add a new Question associated to the userID
+// If question is not valid it will result in an error
+func (s *MemoryStorage) Add(userID int, question models.Question) (q models.Question, err error) {
+	question = question.WithDefaults()
+	err = question.Validate()
+	if err != nil {
+		return q, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		question.ID = s.data.nextID()
+		question.Options = s.newOptions(question.Options, question.ID)
+		question.CreatedAt = timestamp()
+		question.UpdatedAt = question.CreatedAt
+		question.CreatedBy = userID
+		question.UpdatedBy = userID
+		question.DeletedAt = nil
+		question.Version = 1
+		s.data.remember(s.data.questions, question.ID)
+		s.data.questions[question.ID] = memoryQuestion{
+			Question: copyAnswers(question),
+			userID:   userID,
+			tagIDs:   s.tagIDs(userID, models.NormalizeTags(question.Tags)),
+		}
+		q, err = s.saveRevision(question.ID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return q, nil
+}
+
+// newOptions returns a copy of options with new IDs, all belonging to questionID
+func (s *MemoryStorage) newOptions(options []models.Option, questionID int) []models.Option {
+	return s.replaceOptions(nil, options, questionID)
+}
+
+// replaceOptions returns a copy of options that replace current, all belonging to questionID
+// Options without an ID get a new one, timestamps of options that didn't change are kept
+func (s *MemoryStorage) replaceOptions(current, options []models.Option, questionID int) []models.Option {
+	byID := make(map[int]models.Option, len(current))
+	for position, option := range current {
+		option.Position = position
+		byID[option.ID] = option
+	}
+	now := timestamp()
+	replaced := make([]models.Option, len(options))
+	for position, option := range options {
+		if option.ID == 0 {
+			option.ID = s.data.nextID()
+		}
+		replaced[position] = models.Option{
+			ID:         option.ID,
+			Body:       option.Body,
+			Correct:    option.Correct,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			QuestionID: questionID,
+		}
+		if previous, ok := byID[option.ID]; ok {
+			replaced[position].CreatedAt = previous.CreatedAt
+			replaced[position].UpdatedAt = optionUpdatedAt(previous, option, position)
+		}
+	}
+	return replaced
+}
+
+// Get a question by ID, will only return questions userID has at least the viewer role on
+// If the question doesn't exist, is deleted or userID has no role on it it will result in ErrNotFound or ErrForbidden
+func (s *MemoryStorage) Get(id, userID int) (models.Question, error) {
+	return s.get(id, userID, models.RoleViewer, false)
+}
+
+// get returns the question id if userID has at least role on it, deleted says if it has to be in the trash or must not be
+func (s *MemoryStorage) get(id, userID int, role string, deleted bool) (q models.Question, err error) {
+	s.read(func() {
+		question, ok := s.question(id)
+		granted := s.role(question, userID)
+		if !ok || (question.DeletedAt != nil) != deleted {
+			err = notFound("question", id)
+		} else if granted == "" {
+			err = forbidden("question", id)
+		} else if !models.RoleAllows(granted, role) {
+			err = requiresRole(id, role)
+		} else {
+			q = question.Question
+		}
+	})
+	return
+}
+
+// role returns the role of userID on question, the user who created it is always its owner
+// It is empty if userID has no role on the question
+func (s *MemoryStorage) role(question memoryQuestion, userID int) string {
+	if question.userID == userID {
+		return models.RoleOwner
+	}
+	return s.data.shares[question.ID][userID].Role
+}
+
+// Update replaces an existing question with question
+// Options with an ID are updated, options without an ID are added and options missing from question are deleted,
+// the order of question.Options is kept
+// If question.Version is set and the question was changed since, it will result in ErrVersionMismatch
+// If userID isn't at least an editor of the question or question is not valid it will result in an error
+func (s *MemoryStorage) Update(id, userID int, question models.Question) (updated models.Question, err error) {
+	question = question.WithDefaults()
+	err = question.Validate()
+	if err != nil {
+		return models.Question{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		currentQ, err := s.get(id, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		err = currentQ.ValidateReplacement(question)
+		if err != nil {
+			return err
+		}
+		if question.Version != 0 && question.Version != currentQ.Version {
+			return fmt.Errorf("question %d: %w %d", id, ErrVersionMismatch, question.Version)
+		}
+		stored := s.data.questions[id]
+		options := s.replaceOptions(stored.Options, question.Options, id)
+		stored.Body = question.Body
+		stored.ShuffleOptions = question.ShuffleOptions
+		stored.Difficulty = question.Difficulty
+		stored.TimeLimit = question.TimeLimit
+		stored.Points = question.Points
+		stored.Explanation = question.Explanation
+		question = copyAnswers(question)
+		stored.Type = question.Type
+		stored.AcceptedAnswers = question.AcceptedAnswers
+		stored.NumericAnswer = question.NumericAnswer
+		stored.Options = options
+		// tags belong to the user who created the question, no matter who edits it
+		stored.tagIDs = s.tagIDs(stored.userID, models.NormalizeTags(question.Tags))
+		stored.UpdatedAt = timestamp()
+		stored.UpdatedBy = userID
+		stored.Version++
+		s.data.remember(s.data.questions, id)
+		s.data.questions[id] = stored
+		updated, err = s.saveRevision(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return updated, nil
+}
+
+// setOptions replaces the options of the question id after checking that the result is a valid question
+func (s *MemoryStorage) setOptions(id, userID int, question models.Question) (models.Question, error) {
+	err := question.Validate()
+	if err != nil {
+		return models.Question{}, err
+	}
+	stored := s.data.questions[id]
+	stored.Options = s.replaceOptions(stored.Options, question.Options, id)
+	stored.UpdatedAt = timestamp()
+	stored.UpdatedBy = userID
+	stored.Version++
+	s.data.remember(s.data.questions, id)
+	s.data.questions[id] = stored
+	return s.saveRevision(id, userID)
+}
+
+// AddOption adds an Option to an existing question
+// If userID isn't at least an editor of the question or the result is not a valid question it will result in an error
+func (s *MemoryStorage) AddOption(option models.Option, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		option.ID = s.data.nextID()
+		question.Options = append(question.Options, option)
+		question, err = s.setOptions(questionID, userID, question)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// UpdateOption updates an existing option
+// If userID isn't at least an editor of the question or the result is not a valid question it will result in an error
+func (s *MemoryStorage) UpdateOption(option models.Option, optionID, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		found := false
+		for i, currentOption := range question.Options {
+			if currentOption.ID == optionID {
+				option.ID = optionID
+				question.Options[i] = option
+				found = true
+			}
+		}
+		if !found {
+			return notFound("option", optionID)
+		}
+		question, err = s.setOptions(questionID, userID, question)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// DeleteOption deletes an existing option from a question
+// If userID isn't at least an editor of the question, it doesn't exist or the result is not a valid question it will result in an error
+func (s *MemoryStorage) DeleteOption(optionID, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		var options []models.Option
+		for _, option := range question.Options {
+			if option.ID != optionID {
+				options = append(options, option)
+			}
+		}
+		if len(options) == len(question.Options) {
+			return notFound("option", optionID)
+		}
+		question.Options = options
+		question, err = s.setOptions(questionID, userID, question)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// ReorderOptions changes the order of the options of a question to the order of optionIDs
+// optionIDs must contain every option of the question exactly once, otherwise it will result in an error
+func (s *MemoryStorage) ReorderOptions(optionIDs []int, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		err = question.ValidateReorder(optionIDs)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]models.Option)
+		for _, option := range question.Options {
+			byID[option.ID] = option
+		}
+		for position, optionID := range optionIDs {
+			question.Options[position] = byID[optionID]
+		}
+		question, err = s.setOptions(questionID, userID, question)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// Delete moves an existing question to the trash, storing a new revision, and removes it from all tests
+// If userID isn't an owner of the question or it doesn't exist it will result in an error
+func (s *MemoryStorage) Delete(id, userID int) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		_, err := s.get(id, userID, models.RoleOwner, false)
+		if err != nil {
+			return err
+		}
+		deletedAt := timestamp()
+		stored := s.data.questions[id]
+		stored.DeletedAt = &deletedAt
+		stored.UpdatedAt = deletedAt
+		stored.UpdatedBy = userID
+		stored.Version++
+		s.data.remember(s.data.questions, id)
+		s.data.questions[id] = stored
+		s.removeFromTests(id)
+		_, err = s.saveRevision(id, userID)
+		return err
+	})
+}
+
+// ListTrash returns the deleted questions userID owns, the most recently deleted first
+func (s *MemoryStorage) ListTrash(userID int) (questions []models.Question, err error) {
+	questions = []models.Question{}
+	s.read(func() {
+		for id, stored := range s.data.questions {
+			if s.role(stored, userID) == models.RoleOwner && stored.DeletedAt != nil {
+				question, _ := s.question(id)
+				questions = append(questions, question.Question)
+			}
+		}
+	})
+	sort.Slice(questions, func(i, j int) bool {
+		if !questions[i].DeletedAt.Equal(*questions[j].DeletedAt) {
+			return questions[i].DeletedAt.After(*questions[j].DeletedAt)
+		}
+		return questions[i].ID > questions[j].ID
+	})
+	return questions, nil
+}
+
+// RestoreQuestion moves a deleted question out of the trash, it isn't added to the tests it was removed from
+// If the question isn't in the trash or userID isn't an owner of it it will result in an error
+func (s *MemoryStorage) RestoreQuestion(id, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		_, err := s.get(id, userID, models.RoleOwner, true)
+		if err != nil {
+			return err
+		}
+		stored := s.data.questions[id]
+		stored.DeletedAt = nil
+		stored.UpdatedAt = timestamp()
+		stored.UpdatedBy = userID
+		stored.Version++
+		s.data.remember(s.data.questions, id)
+		s.data.questions[id] = stored
+		question, err = s.saveRevision(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// PurgeDeleted permanently deletes every question that was moved to the trash before before
+// It returns the number of deleted questions
+func (s *MemoryStorage) PurgeDeleted(before time.Time) (purged int, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		for id, question := range s.data.questions {
+			if question.DeletedAt != nil && question.DeletedAt.Before(before) {
+				s.data.remember(s.data.questions, id)
+				delete(s.data.questions, id)
+				s.data.remember(s.data.revisions, id)
+				delete(s.data.revisions, id)
+				s.data.remember(s.data.shares, id)
+				delete(s.data.shares, id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// CreateUser creates a new user with username and password, only the hash of password is stored
+// If the user already exists it will return an error
+func (s *MemoryStorage) CreateUser(username, password string) (user models.UserResponse, err error) {
+	hash, err := s.Passwords.Hash(password)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		if _, ok := s.userByName(username); ok {
+			return fmt.Errorf("username %q %w", username, ErrConflict)
+		}
+		id := s.data.nextID()
+		now := timestamp()
+		s.data.remember(s.data.users, id)
+		s.data.users[id] = models.User{ID: id, Username: username, Password: hash, CreatedAt: now, UpdatedAt: now}
+		user = models.UserResponse{ID: id, Username: username, CreatedAt: now, UpdatedAt: now}
+		return nil
+	})
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	return user, nil
+}
+
+// userByName returns the user with username
+func (s *MemoryStorage) userByName(username string) (models.User, bool) {
+	for _, user := range s.data.users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
+
+// CreateToken creates a new access token and refresh token for the user
+// If username and password are incorrect it will result in ErrInvalidCredentials
+// Hashes with an outdated cost are rehashed on success
+func (s *MemoryStorage) CreateToken(username, password string, config TokenConfig) (token models.JWTTokenResponse, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		user, ok := s.userByName(username)
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		ok, needsRehash := s.Passwords.Verify(user.Password, password)
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		if needsRehash {
+			hash, err := s.Passwords.Hash(password)
+			if err != nil {
+				log.Print(err)
+			} else {
+				user.Password = hash
+				s.data.remember(s.data.users, user.ID)
+				s.data.users[user.ID] = user
+			}
+		}
+		token, err = s.issueTokens(user.ID, config)
+		return err
+	})
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return token, nil
+}
+
+// issueTokens stores a new refresh token for userID and returns it together with a new access token
+func (s *MemoryStorage) issueTokens(userID int, config TokenConfig) (models.JWTTokenResponse, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	hash := hashRefreshToken(refreshToken)
+	s.data.remember(s.data.refreshTokens, hash)
+	s.data.refreshTokens[hash] = memoryRefreshToken{
+		userID:    userID,
+		expiresAt: time.Now().Add(config.RefreshTTL),
+	}
+	return newTokenResponse(userID, refreshToken, config)
+}
+
+// RefreshToken exchanges a valid refresh token for a new access token and refresh token
+// The used refresh token is revoked, if it is unknown, expired or revoked it will result in ErrInvalidToken
+func (s *MemoryStorage) RefreshToken(refreshToken string, config TokenConfig) (token models.JWTTokenResponse, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		hash := hashRefreshToken(refreshToken)
+		stored, ok := s.data.refreshTokens[hash]
+		if !ok || stored.revoked || time.Now().After(stored.expiresAt) {
+			return ErrInvalidToken
+		}
+		stored.revoked = true
+		s.data.remember(s.data.refreshTokens, hash)
+		s.data.refreshTokens[hash] = stored
+		token, err = s.issueTokens(stored.userID, config)
+		return err
+	})
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return token, nil
+}
+
+// RevokeTokens revokes the refresh token of userID and denylists the access token jti until it expires
+func (s *MemoryStorage) RevokeTokens(userID int, refreshToken, jti string, expiresAt time.Time) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		hash := hashRefreshToken(refreshToken)
+		if stored, ok := s.data.refreshTokens[hash]; ok && stored.userID == userID {
+			stored.revoked = true
+			s.data.remember(s.data.refreshTokens, hash)
+			s.data.refreshTokens[hash] = stored
+		}
+		if _, ok := s.data.revokedTokens[jti]; !ok {
+			s.data.remember(s.data.revokedTokens, jti)
+			s.data.revokedTokens[jti] = expiresAt
+		}
+		// expired access tokens are rejected anyway, no need to keep their jti around
+		for revokedJTI, revokedExpiresAt := range s.data.revokedTokens {
+			if revokedExpiresAt.Before(time.Now()) {
+				s.data.remember(s.data.revokedTokens, revokedJTI)
+				delete(s.data.revokedTokens, revokedJTI)
+			}
+		}
+		return nil
+	})
+}
+
+// IsTokenRevoked checks if the access token with jti has been revoked
+func (s *MemoryStorage) IsTokenRevoked(jti string) (revoked bool) {
+	s.read(func() {
+		_, revoked = s.data.revokedTokens[jti]
+	})
+	return
+}
+
+// UserIDExists checks if a given userID exists
+func (s *MemoryStorage) UserIDExists(userID int) (exists bool) {
+	s.read(func() {
+		_, exists = s.data.users[userID]
+	})
+	return
+}
+
+// HasQuestionAccess verifies that a userID has access to a questionID
+// Returns true if the user has any role on the question and false if not
+func (s *MemoryStorage) HasQuestionAccess(userID, questionID int) (access bool) {
+	s.read(func() {
+		question, ok := s.data.questions[questionID]
+		access = ok && s.role(question, userID) != "" && question.DeletedAt == nil
+	})
+	return
+}
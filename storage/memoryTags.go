@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"sort"
+)
+
+// memoryTag is a tag with the user it belongs to, QuestionCount is not stored
+type memoryTag struct {
+	models.Tag
+	userID int
+}
+
+// tagIDs returns the IDs of the tags of userID with the normalized names, missing tags are created
+func (s *MemoryStorage) tagIDs(userID int, names []string) []int {
+	ids := make([]int, 0, len(names))
+	for _, name := range names {
+		id, ok := s.tagByName(userID, name)
+		if !ok {
+			id = s.data.nextID()
+			s.data.remember(s.data.tags, id)
+			s.data.tags[id] = memoryTag{Tag: models.Tag{ID: id, Name: name}, userID: userID}
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// tagByName returns the ID of the tag of userID named name
+func (s *MemoryStorage) tagByName(userID int, name string) (int, bool) {
+	for id, tag := range s.data.tags {
+		if tag.userID == userID && tag.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// tagNames returns the sorted names of the tags with ids
+func (s *MemoryStorage) tagNames(ids []int) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, s.data.tags[id].Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasTags checks if a question with the tag names matches the tag filter of page
+func hasTags(names []string, page models.PageRequest) bool {
+	if len(page.Tags) == 0 {
+		return true
+	}
+	has := make(map[string]bool, len(names))
+	for _, name := range names {
+		has[name] = true
+	}
+	matches := 0
+	for _, name := range page.Tags {
+		if has[name] {
+			matches++
+		}
+	}
+	if page.TagMode == models.TagModeAny {
+		return matches > 0
+	}
+	return matches == len(page.Tags)
+}
+
+// tag returns a copy of the stored tag id with its question count set
+func (s *MemoryStorage) tag(id int) (memoryTag, bool) {
+	tag, ok := s.data.tags[id]
+	if !ok {
+		return tag, false
+	}
+	for _, question := range s.data.questions {
+		if question.DeletedAt != nil {
+			continue
+		}
+		for _, tagID := range question.tagIDs {
+			if tagID == id {
+				tag.QuestionCount++
+			}
+		}
+	}
+	return tag, true
+}
+
+// ListTags returns all tags of userID sorted by name
+func (s *MemoryStorage) ListTags(userID int) (tags []models.Tag, err error) {
+	tags = []models.Tag{}
+	s.read(func() {
+		for id, stored := range s.data.tags {
+			if stored.userID == userID {
+				tag, _ := s.tag(id)
+				tags = append(tags, tag.Tag)
+			}
+		}
+	})
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// GetTag returns a tag by ID, will only return tags of userID
+// If the tag doesn't exist or doesn't belong to userID it will result in ErrNotFound or ErrForbidden
+func (s *MemoryStorage) GetTag(id, userID int) (t models.Tag, err error) {
+	s.read(func() {
+		tag, ok := s.tag(id)
+		if !ok {
+			err = notFound("tag", id)
+		} else if tag.userID != userID {
+			err = forbidden("tag", id)
+		} else {
+			t = tag.Tag
+		}
+	})
+	return
+}
+
+// AddTag adds a new tag for userID
+// If tag is not valid or userID already has a tag with the same name it will result in an error
+func (s *MemoryStorage) AddTag(userID int, tag models.Tag) (added models.Tag, err error) {
+	err = tag.Validate()
+	if err != nil {
+		return models.Tag{}, err
+	}
+	name := models.NormalizeTag(tag.Name)
+	err = s.atomic(func(s *MemoryStorage) error {
+		if _, ok := s.tagByName(userID, name); ok {
+			return fmt.Errorf("tag %q %w", name, ErrConflict)
+		}
+		added, err = s.GetTag(s.tagIDs(userID, []string{name})[0], userID)
+		return err
+	})
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return added, nil
+}
+
+// UpdateTag renames an existing tag, the questions with the tag keep it and store a new revision
+// If the tag doesn't belong to userID, tag is not valid or the name is taken it will result in an error
+func (s *MemoryStorage) UpdateTag(id, userID int, tag models.Tag) (updated models.Tag, err error) {
+	err = tag.Validate()
+	if err != nil {
+		return models.Tag{}, err
+	}
+	name := models.NormalizeTag(tag.Name)
+	err = s.atomic(func(s *MemoryStorage) error {
+		_, err := s.GetTag(id, userID)
+		if err != nil {
+			return err
+		}
+		if existing, ok := s.tagByName(userID, name); ok && existing != id {
+			return fmt.Errorf("tag %q %w", name, ErrConflict)
+		}
+		stored := s.data.tags[id]
+		stored.Name = name
+		s.data.remember(s.data.tags, id)
+		s.data.tags[id] = stored
+		err = s.touchQuestions(s.taggedQuestions(id), userID)
+		if err != nil {
+			return err
+		}
+		updated, err = s.GetTag(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return updated, nil
+}
+
+// DeleteTag deletes an existing tag and removes it from all questions, storing a new revision of each
+// If the tag doesn't exist or doesn't belong to userID it will result in an error
+func (s *MemoryStorage) DeleteTag(id, userID int) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		_, err := s.GetTag(id, userID)
+		if err != nil {
+			return err
+		}
+		s.data.remember(s.data.tags, id)
+		delete(s.data.tags, id)
+		tagged := s.taggedQuestions(id)
+		for _, questionID := range tagged {
+			question := s.data.questions[questionID]
+			var tagIDs []int
+			for _, tagID := range question.tagIDs {
+				if tagID != id {
+					tagIDs = append(tagIDs, tagID)
+				}
+			}
+			question.tagIDs = tagIDs
+			s.data.remember(s.data.questions, questionID)
+			s.data.questions[questionID] = question
+		}
+		return s.touchQuestions(tagged, userID)
+	})
+}
+
+// taggedQuestions returns the IDs of the questions with the tag id in ascending order
+func (s *MemoryStorage) taggedQuestions(id int) []int {
+	ids := []int{}
+	for questionID, question := range s.data.questions {
+		for _, tagID := range question.tagIDs {
+			if tagID == id {
+				ids = append(ids, questionID)
+				break
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"github.com/makupi/backend-homework/models"
+	"sort"
+)
+
+// memoryTest is a test with the user it belongs to
+type memoryTest struct {
+	models.Test
+	userID int
+}
+
+// checkQuestions verifies that every question of questionIDs exists and userID has a role on it
+// The first missing, deleted or inaccessible question results in ErrNotFound or ErrForbidden
+func (s *MemoryStorage) checkQuestions(userID int, questionIDs []int) error {
+	for _, id := range questionIDs {
+		question, ok := s.data.questions[id]
+		if !ok || question.DeletedAt != nil {
+			return notFound("question", id)
+		}
+		if s.role(question, userID) == "" {
+			return forbidden("question", id)
+		}
+	}
+	return nil
+}
+
+// removeFromTests removes the deleted question questionID from all tests
+func (s *MemoryStorage) removeFromTests(questionID int) {
+	for id, test := range s.data.tests {
+		test.QuestionIDs, _ = removeID(test.QuestionIDs, questionID)
+		s.data.remember(s.data.tests, id)
+		s.data.tests[id] = test
+	}
+}
+
+// ListTests returns all tests of userID in the order they were created
+func (s *MemoryStorage) ListTests(userID int) (tests []models.Test, err error) {
+	tests = []models.Test{}
+	s.read(func() {
+		for _, test := range s.data.tests {
+			if test.userID == userID {
+				test.QuestionIDs = append([]int{}, test.QuestionIDs...)
+				tests = append(tests, test.Test)
+			}
+		}
+	})
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].ID < tests[j].ID
+	})
+	return tests, nil
+}
+
+// GetTest returns a test by ID, will only return tests of userID
+// If the test doesn't exist or doesn't belong to userID it will result in ErrNotFound or ErrForbidden
+func (s *MemoryStorage) GetTest(id, userID int) (t models.Test, err error) {
+	s.read(func() {
+		test, ok := s.data.tests[id]
+		if !ok {
+			err = notFound("test", id)
+		} else if test.userID != userID {
+			err = forbidden("test", id)
+		} else {
+			t = test.Test
+			t.QuestionIDs = append([]int{}, test.QuestionIDs...)
+		}
+	})
+	return
+}
+
+// AddTest adds a new test for userID
+// If test is not valid or contains questions of other users it will result in an error
+func (s *MemoryStorage) AddTest(userID int, test models.Test) (added models.Test, err error) {
+	err = test.Validate()
+	if err != nil {
+		return models.Test{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		err := s.checkQuestions(userID, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		test.ID = s.data.nextID()
+		test.QuestionIDs = append([]int{}, test.QuestionIDs...)
+		s.data.remember(s.data.tests, test.ID)
+		s.data.tests[test.ID] = memoryTest{Test: test, userID: userID}
+		added, err = s.GetTest(test.ID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return added, nil
+}
+
+// UpdateTest replaces an existing test with test, including the order of its questions
+// If the test doesn't belong to userID, test is not valid or contains questions of other users it will result in an error
+func (s *MemoryStorage) UpdateTest(id, userID int, test models.Test) (updated models.Test, err error) {
+	err = test.Validate()
+	if err != nil {
+		return models.Test{}, err
+	}
+	err = s.atomic(func(s *MemoryStorage) error {
+		_, err := s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		err = s.checkQuestions(userID, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		test.ID = id
+		test.QuestionIDs = append([]int{}, test.QuestionIDs...)
+		s.data.remember(s.data.tests, id)
+		s.data.tests[id] = memoryTest{Test: test, userID: userID}
+		updated, err = s.GetTest(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return updated, nil
+}
+
+// DeleteTest deletes an existing test, its questions are kept
+// If the test doesn't exist or doesn't belong to userID it will result in an error
+func (s *MemoryStorage) DeleteTest(id, userID int) error {
+	return s.atomic(func(s *MemoryStorage) error {
+		_, err := s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		s.data.remember(s.data.tests, id)
+		delete(s.data.tests, id)
+		return nil
+	})
+}
+
+// AddTestQuestions appends questions of the library of userID to the test id
+// Questions that are already part of the test keep their position
+// If the test or a question doesn't belong to userID it will result in an error
+func (s *MemoryStorage) AddTestQuestions(id, userID int, questionIDs []int) (test models.Test, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		test, err = s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		test.QuestionIDs = appendMissing(test.QuestionIDs, questionIDs)
+		test, err = s.UpdateTest(id, userID, test)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return test, nil
+}
+
+// RemoveTestQuestion removes a question from the test id, the question itself is kept
+// If the test doesn't belong to userID or doesn't contain the question it will result in an error
+func (s *MemoryStorage) RemoveTestQuestion(id, questionID, userID int) (test models.Test, err error) {
+	err = s.atomic(func(s *MemoryStorage) error {
+		test, err = s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		remaining, ok := removeID(test.QuestionIDs, questionID)
+		if !ok {
+			return notFound("question", questionID)
+		}
+		stored := s.data.tests[id]
+		stored.QuestionIDs = remaining
+		s.data.remember(s.data.tests, id)
+		s.data.tests[id] = stored
+		test, err = s.GetTest(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return test, nil
+}
+
+// HasTestAccess verifies that a userID has access to a testID
+func (s *MemoryStorage) HasTestAccess(userID, testID int) (access bool) {
+	s.read(func() {
+		test, ok := s.data.tests[testID]
+		access = ok && test.userID == userID
+	})
+	return
+}
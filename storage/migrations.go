@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer version of the app
+var ErrSchemaTooNew = errors.New("database schema is newer than this version supports")
+
+// Migration is a numbered schema change, Up applies it and Down reverts it
+// Versions start at 1 and have to be consecutive
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// Migrator applies and reverts migrations and keeps track of them in the schema_version table
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	dialect    dialect
+	// baseline returns the version of a database that was created before schema_version existed
+	baseline func() (int, error)
+}
+
+const createSchemaVersion = `CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+);`
+
+// Latest returns the version of the newest known migration
+func (m *Migrator) Latest() int {
+	return len(m.Migrations)
+}
+
+// Version returns the version the database is currently migrated to, 0 for an empty database
+func (m *Migrator) Version() (int, error) {
+	err := m.init()
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = m.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// Check returns ErrSchemaTooNew if the database has migrations applied that are unknown to m
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies all pending migrations up to and including target, each in its own transaction
+func (m *Migrator) Up(target int) error {
+	if target > m.Latest() {
+		return fmt.Errorf("unknown migration %d, latest is %d", target, m.Latest())
+	}
+	err := m.Check()
+	if err != nil {
+		return err
+	}
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.Migrations[version:target] {
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Description)
+		err = m.apply(migration.Up,
+			m.dialect.rebind(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`),
+			migration.Version, migration.Description, time.Now().UTC().Format(time.RFC3339),
+		)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts all applied migrations newer than target, each in its own transaction
+func (m *Migrator) Down(target int) error {
+	if target < 0 {
+		return fmt.Errorf("invalid target version %d", target)
+	}
+	err := m.Check()
+	if err != nil {
+		return err
+	}
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	for i := version - 1; i >= target; i-- {
+		migration := m.Migrations[i]
+		log.Printf("Reverting migration %d: %s", migration.Version, migration.Description)
+		err = m.apply(migration.Down, m.dialect.rebind(`DELETE FROM schema_version WHERE version = ?`), migration.Version)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// apply runs statements followed by the bookkeeping query in a single transaction
+func (m *Migrator) apply(statements []string, query string, args ...interface{}) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// init creates the schema_version table
+// Databases created before schema_version existed are recorded at their baseline version without running migrations
+func (m *Migrator) init() error {
+	var exists int
+	err := m.DB.QueryRow(m.dialect.rebind(m.dialect.tableExists()), "schema_version").Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+	baseline := 0
+	if m.baseline != nil {
+		baseline, err = m.baseline()
+		if err != nil {
+			return err
+		}
+	}
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(createSchemaVersion)
+	for _, migration := range m.Migrations[:baseline] {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec(
+			m.dialect.rebind(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`),
+			migration.Version, migration.Description, time.Now().UTC().Format(time.RFC3339),
+		)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
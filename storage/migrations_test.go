@@ -0,0 +1,214 @@
+package storage_test
+
+import (
+	"github.com/makupi/backend-homework/storage"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newSqliteMigrator returns a Migrator for a new SQLite database at path
+func newSqliteMigrator(t *testing.T, path string) *storage.Migrator {
+	t.Helper()
+	migrator, err := storage.NewSqliteMigrator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { migrator.DB.Close() })
+	return migrator
+}
+
+func TestSqliteMigrations(t *testing.T) {
+	testMigrations(t, newSqliteMigrator(t, filepath.Join(t.TempDir(), "db.sqlite3")))
+}
+
+// TestPostgresMigrations runs against the database in POSTGRES_TEST_URL, every table in it will be dropped
+func TestPostgresMigrations(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+	migrator, err := storage.NewPostgresMigrator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer migrator.DB.Close()
+	err = migrator.Down(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMigrations(t, migrator)
+}
+
+// testMigrations applies and reverts every migration of an empty database one by one
+func testMigrations(t *testing.T, migrator *storage.Migrator) {
+	assertVersion := func(want int) {
+		t.Helper()
+		version, err := migrator.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want {
+			t.Fatalf("got version %d, want %d", version, want)
+		}
+	}
+	assertVersion(0)
+	for version := 1; version <= migrator.Latest(); version++ {
+		err := migrator.Up(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertVersion(version)
+		err = migrator.Down(version - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertVersion(version - 1)
+		err = migrator.Up(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertVersion(migrator.Latest())
+
+	err := migrator.Up(migrator.Latest() + 1)
+	if err == nil {
+		t.Fatal("migrated up to an unknown version")
+	}
+	err = migrator.Down(-1)
+	if err == nil {
+		t.Fatal("migrated down to a negative version")
+	}
+
+	err = migrator.Down(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertVersion(0)
+	err = migrator.Up(migrator.Latest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertVersion(migrator.Latest())
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	migrator := newSqliteMigrator(t, path)
+	err := migrator.Up(migrator.Latest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.DB.Exec(
+		`INSERT INTO schema_version (version, description, applied_at) VALUES (?, 'from the future', '')`,
+		migrator.Latest()+1,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIs(t, migrator.Check(), storage.ErrSchemaTooNew)
+	assertIs(t, migrator.Up(migrator.Latest()), storage.ErrSchemaTooNew)
+	assertIs(t, migrator.Down(0), storage.ErrSchemaTooNew)
+	_, err = storage.NewSqliteStorage(path, passwords)
+	assertIs(t, err, storage.ErrSchemaTooNew)
+}
+
+// TestSqliteBaseline opens databases created before schema_version existed
+// They are simulated by migrating to a version and dropping schema_version afterwards
+func TestSqliteBaseline(t *testing.T) {
+	tests := map[string]struct {
+		// version the database was created at, baseline the version it has to be recognized as
+		version, baseline int
+	}{
+		// databases from before migrations have every column up to migration 3
+		"legacy": {3, 3},
+		// older databases without shuffle_options are migrated from the start, migration 1 keeps existing tables
+		"partially migrated": {1, 0},
+		"empty":              {0, 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db.sqlite3")
+			migrator := newSqliteMigrator(t, path)
+			err := migrator.Up(test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.version > 0 {
+				_, err = migrator.DB.Exec(`INSERT INTO users (username, password) VALUES ('alice', 'password')`)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err = migrator.DB.Exec(`DROP TABLE schema_version`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrator.DB.Close()
+
+			migrator = newSqliteMigrator(t, path)
+			version, err := migrator.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != test.baseline {
+				t.Fatalf("got baseline %d, want %d", version, test.baseline)
+			}
+			migrator.DB.Close()
+
+			s, err := storage.NewSqliteStorage(path, passwords)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.DB.Close()
+			// existing users keep their legacy password and are able to log in
+			userID := 1
+			if test.version == 0 {
+				userID = newUser(t, s, "alice")
+			}
+			_, err = s.CreateToken("alice", "password", tokens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newQuestion(t, s, userID)
+		})
+	}
+}
+
+// TestSqliteBackfillRevisions opens a database with a question from before revisions were recorded
+func TestSqliteBackfillRevisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	s, err := storage.NewSqliteStorage(path, passwords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	_, err = s.DB.Exec(`DELETE FROM question_revisions`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DB.Close()
+
+	for i := 0; i < 2; i++ {
+		s, err = storage.NewSqliteStorage(path, passwords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revisions, err := s.ListRevisions(question.ID, userID)
+		s.DB.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 1 {
+			t.Fatalf("got %d revisions, want 1", len(revisions))
+		}
+		baseline := revisions[0]
+		if baseline.Number != 1 || baseline.AuthorID != userID || !baseline.CreatedAt.Equal(question.UpdatedAt) {
+			t.Fatalf("got revision %+v", baseline)
+		}
+		if baseline.Question.Body != question.Body || len(baseline.Question.Options) != 2 {
+			t.Fatalf("got snapshot %+v", baseline.Question)
+		}
+	}
+}
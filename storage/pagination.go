@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"github.com/makupi/backend-homework/models"
+	"sort"
+)
+
+// fetchPage returns up to page.Limit+1 of questions following page.Cursor in the order the SQL databases fetch them,
+// the way models.NewQuestionPage expects them
+// Used wherever questions can't be sorted and paginated by the database
+func fetchPage(questions []models.Question, page models.PageRequest) []models.Question {
+	// pages before the cursor are fetched in reverse order
+	backwards := page.Cursor != nil && page.Cursor.Before
+	fetchedBefore := func(a, b models.Question) bool {
+		if backwards {
+			return listedBefore(page.Sort, b, a)
+		}
+		return listedBefore(page.Sort, a, b)
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		return fetchedBefore(questions[i], questions[j])
+	})
+	fetched := []models.Question{}
+	for _, question := range questions {
+		if len(fetched) > page.Limit {
+			break
+		}
+		if page.Cursor == nil || fetchedBefore(cursorQuestion(*page.Cursor), question) {
+			fetched = append(fetched, question)
+		}
+	}
+	return fetched
+}
+
+// cursorQuestion returns a question with the sort keys of cursor
+func cursorQuestion(cursor models.Cursor) models.Question {
+	return models.Question{ID: cursor.ID, UpdatedAt: cursor.UpdatedAt, Rank: cursor.Rank}
+}
+
+// listedBefore reports whether a is listed before b when sorted by sortOrder
+func listedBefore(sortOrder string, a, b models.Question) bool {
+	switch sortOrder {
+	case models.SortOldest:
+		return a.ID < b.ID
+	case models.SortUpdated:
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+	case models.SortRelevance:
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+	}
+	return a.ID > b.ID
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"crypto/subtle"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes and verifies user passwords with bcrypt
+type PasswordHasher struct {
+	Cost int
+}
+
+// NewPasswordHasher returns a PasswordHasher using cost, falling back to bcrypt.DefaultCost if cost is out of range
+func NewPasswordHasher(cost int) PasswordHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return PasswordHasher{Cost: cost}
+}
+
+// Hash returns the bcrypt hash of password
+func (p PasswordHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), p.Cost)
+	return string(hash), err
+}
+
+// Verify compares password against the stored value in constant time
+// stored may be a bcrypt hash or a legacy plaintext password,
+// needsRehash is true if the password matched but stored should be replaced with a fresh hash
+func (p PasswordHasher) Verify(stored, password string) (ok, needsRehash bool) {
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		// not a bcrypt hash, stored is a plaintext password from before hashing was introduced
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	return true, cost != p.Cost
+}
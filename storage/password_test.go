@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"github.com/makupi/backend-homework/storage"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	for _, cost := range []int{0, bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if got := storage.NewPasswordHasher(cost).Cost; got != bcrypt.DefaultCost {
+			t.Fatalf("cost %d: got %d, want %d", cost, got, bcrypt.DefaultCost)
+		}
+	}
+	if got := storage.NewPasswordHasher(bcrypt.MinCost).Cost; got != bcrypt.MinCost {
+		t.Fatalf("got %d, want %d", got, bcrypt.MinCost)
+	}
+}
+
+func TestPasswordVerify(t *testing.T) {
+	hash, err := passwords.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated, err := storage.NewPasswordHasher(bcrypt.MinCost + 1).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		stored, password string
+		ok, needsRehash  bool
+	}{
+		"hash":                     {hash, "password", true, false},
+		"hash wrong password":      {hash, "wrong", false, false},
+		"outdated cost":            {outdated, "password", true, true},
+		"outdated wrong password":  {outdated, "wrong", false, false},
+		"plaintext":                {"password", "password", true, true},
+		"plaintext wrong password": {"password", "wrong", false, false},
+		"plaintext prefix":         {"password", "pass", false, false},
+		"hash as password":         {hash, hash, false, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok, needsRehash := passwords.Verify(test.stored, test.password)
+			if ok != test.ok || needsRehash != test.needsRehash {
+				t.Fatalf("got %v, %v, want %v, %v", ok, needsRehash, test.ok, test.needsRehash)
+			}
+		})
+	}
+}
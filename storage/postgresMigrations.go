@@ -0,0 +1,415 @@
+package storage
+
+// postgresMigrations are the schema migrations for PostgresStorage
+// They create the same tables as sqliteMigrations and have to be kept in sync with them
+var postgresMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, questions and options",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "users" (
+				"id" SERIAL PRIMARY KEY,
+				"username" TEXT NOT NULL UNIQUE,
+				"password" TEXT
+			);`,
+			`CREATE TABLE IF NOT EXISTS "questions" (
+				"id" SERIAL PRIMARY KEY,
+				"question" TEXT,
+				"user_id" INTEGER NOT NULL,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "options" (
+				"id" SERIAL PRIMARY KEY,
+				"question_id" INTEGER NOT NULL,
+				"option" TEXT,
+				"correct" BOOLEAN,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "options";`,
+			`DROP TABLE "questions";`,
+			`DROP TABLE "users";`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create refresh_tokens and revoked_tokens",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+				"id" SERIAL PRIMARY KEY,
+				"token_hash" TEXT NOT NULL UNIQUE,
+				"user_id" INTEGER NOT NULL,
+				"expires_at" TIMESTAMPTZ NOT NULL,
+				"revoked" BOOLEAN NOT NULL DEFAULT FALSE,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+				"jti" TEXT NOT NULL PRIMARY KEY,
+				"expires_at" TIMESTAMPTZ NOT NULL
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "revoked_tokens";`,
+			`DROP TABLE "refresh_tokens";`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add options.position and questions.shuffle_options",
+		Up: []string{
+			`ALTER TABLE "options" ADD COLUMN "position" INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE "questions" ADD COLUMN "shuffle_options" BOOLEAN NOT NULL DEFAULT FALSE;`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "shuffle_options";`,
+			`ALTER TABLE "options" DROP COLUMN "position";`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add questions.updated_at for sorting by last update",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`CREATE INDEX "questions_user_updated" ON "questions" ("user_id", "updated_at", "id");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_user_updated";`,
+			`ALTER TABLE "questions" DROP COLUMN "updated_at";`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "index options by question and questions by user",
+		Up: []string{
+			`CREATE INDEX "options_question_position" ON "options" ("question_id", "position", "id");`,
+			`CREATE INDEX "questions_user" ON "questions" ("user_id", "id");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_user";`,
+			`DROP INDEX "options_question_position";`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add full-text search over questions and options",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "search" TSVECTOR;`,
+			// the simple configuration doesn't stem, so search behaves like it does on SQLite
+			// $1 is the body of the question and $2 its id, names would be shadowed by the columns of options
+			`CREATE FUNCTION questions_search(TEXT, INTEGER) RETURNS TSVECTOR AS $$
+				SELECT setweight(to_tsvector('simple', $1), 'A') || setweight(to_tsvector('simple',
+					COALESCE((SELECT string_agg("option", ' ') FROM "options" WHERE "question_id" = $2), '')
+				), 'B')
+			$$ LANGUAGE SQL STABLE;`,
+			`UPDATE "questions" SET "search" = questions_search("question", "id");`,
+			`CREATE INDEX "questions_search" ON "questions" USING GIN ("search");`,
+			`CREATE FUNCTION questions_search_trigger() RETURNS TRIGGER AS $$
+			BEGIN
+				NEW.search := questions_search(NEW.question, NEW.id);
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql;`,
+			`CREATE TRIGGER "questions_search" BEFORE INSERT OR UPDATE OF "question" ON "questions"
+				FOR EACH ROW EXECUTE PROCEDURE questions_search_trigger();`,
+			`CREATE FUNCTION options_search_trigger() RETURNS TRIGGER AS $$
+			BEGIN
+				IF TG_OP <> 'DELETE' THEN
+					UPDATE questions SET search = questions_search(question, id) WHERE id = NEW.question_id;
+				END IF;
+				IF TG_OP <> 'INSERT' THEN
+					UPDATE questions SET search = questions_search(question, id) WHERE id = OLD.question_id;
+				END IF;
+				RETURN NULL;
+			END
+			$$ LANGUAGE plpgsql;`,
+			`CREATE TRIGGER "options_search" AFTER INSERT OR UPDATE OF "option" OR DELETE ON "options"
+				FOR EACH ROW EXECUTE PROCEDURE options_search_trigger();`,
+		},
+		Down: []string{
+			`DROP TRIGGER "options_search" ON "options";`,
+			`DROP FUNCTION options_search_trigger();`,
+			`DROP TRIGGER "questions_search" ON "questions";`,
+			`DROP FUNCTION questions_search_trigger();`,
+			`DROP INDEX "questions_search";`,
+			`ALTER TABLE "questions" DROP COLUMN "search";`,
+			`DROP FUNCTION questions_search(TEXT, INTEGER);`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "create tags and question_tags",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "tags" (
+				"id" SERIAL PRIMARY KEY,
+				"user_id" INTEGER NOT NULL,
+				"name" TEXT NOT NULL,
+				UNIQUE ("user_id", "name"),
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "question_tags" (
+				"question_id" INTEGER NOT NULL,
+				"tag_id" INTEGER NOT NULL,
+				PRIMARY KEY ("question_id", "tag_id"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_tag_id
+					FOREIGN KEY (tag_id)
+					REFERENCES tags(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "question_tags_tag" ON "question_tags" ("tag_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "question_tags";`,
+			`DROP TABLE "tags";`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "add difficulty, time limit, points and explanation to questions",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "difficulty" TEXT NOT NULL DEFAULT 'medium';`,
+			`ALTER TABLE "questions" ADD COLUMN "time_limit" INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE "questions" ADD COLUMN "points" INTEGER NOT NULL DEFAULT 1;`,
+			`ALTER TABLE "questions" ADD COLUMN "explanation" TEXT NOT NULL DEFAULT '';`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "explanation";`,
+			`ALTER TABLE "questions" DROP COLUMN "points";`,
+			`ALTER TABLE "questions" DROP COLUMN "time_limit";`,
+			`ALTER TABLE "questions" DROP COLUMN "difficulty";`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "add question types with accepted answers and numeric answers",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "type" TEXT NOT NULL DEFAULT 'multiple_choice';`,
+			`ALTER TABLE "questions" ADD COLUMN "numeric_value" DOUBLE PRECISION;`,
+			`ALTER TABLE "questions" ADD COLUMN "numeric_tolerance" DOUBLE PRECISION;`,
+			`CREATE TABLE IF NOT EXISTS "accepted_answers" (
+				"id" SERIAL PRIMARY KEY,
+				"question_id" INTEGER NOT NULL,
+				"answer" TEXT NOT NULL,
+				"regex" BOOLEAN NOT NULL DEFAULT FALSE,
+				"case_sensitive" BOOLEAN NOT NULL DEFAULT FALSE,
+				"position" INTEGER NOT NULL DEFAULT 0,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "accepted_answers_question_position" ON "accepted_answers" ("question_id", "position");`,
+		},
+		Down: []string{
+			`DROP TABLE "accepted_answers";`,
+			`ALTER TABLE "questions" DROP COLUMN "numeric_tolerance";`,
+			`ALTER TABLE "questions" DROP COLUMN "numeric_value";`,
+			`ALTER TABLE "questions" DROP COLUMN "type";`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "create tests and test_questions",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "tests" (
+				"id" SERIAL PRIMARY KEY,
+				"user_id" INTEGER NOT NULL,
+				"title" TEXT NOT NULL,
+				"description" TEXT NOT NULL DEFAULT '',
+				"time_limit" INTEGER NOT NULL DEFAULT 0,
+				"pass_threshold" INTEGER NOT NULL DEFAULT 0,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "test_questions" (
+				"test_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"position" INTEGER NOT NULL,
+				PRIMARY KEY ("test_id", "question_id"),
+				CONSTRAINT fk_test_id
+					FOREIGN KEY (test_id)
+					REFERENCES tests(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "tests_user" ON "tests" ("user_id", "id");`,
+			`CREATE INDEX "test_questions_question" ON "test_questions" ("question_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "test_questions";`,
+			`DROP TABLE "tests";`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "create attempts, attempt_questions and attempt_answers",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "attempts" (
+				"id" SERIAL PRIMARY KEY,
+				"user_id" INTEGER NOT NULL,
+				"test_id" INTEGER,
+				"candidate" TEXT NOT NULL DEFAULT '',
+				"status" TEXT NOT NULL,
+				"time_limit" INTEGER NOT NULL DEFAULT 0,
+				"pass_threshold" INTEGER NOT NULL DEFAULT 0,
+				"started_at" TIMESTAMPTZ NOT NULL,
+				"expires_at" TIMESTAMPTZ,
+				"submitted_at" TIMESTAMPTZ,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "attempt_questions" (
+				"attempt_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"position" INTEGER NOT NULL,
+				"points" INTEGER NOT NULL,
+				"snapshot" TEXT NOT NULL,
+				PRIMARY KEY ("attempt_id", "position"),
+				CONSTRAINT fk_attempt_id
+					FOREIGN KEY (attempt_id)
+					REFERENCES attempts(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "attempt_answers" (
+				"attempt_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"option_ids" TEXT NOT NULL DEFAULT '[]',
+				"answer" TEXT NOT NULL DEFAULT '',
+				"value" DOUBLE PRECISION,
+				"answered_at" TIMESTAMPTZ NOT NULL,
+				"correct" BOOLEAN NOT NULL DEFAULT FALSE,
+				"points" DOUBLE PRECISION NOT NULL DEFAULT 0,
+				PRIMARY KEY ("attempt_id", "question_id"),
+				CONSTRAINT fk_attempt_id
+					FOREIGN KEY (attempt_id)
+					REFERENCES attempts(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "attempts_user" ON "attempts" ("user_id", "id");`,
+		},
+		Down: []string{
+			`DROP TABLE "attempt_answers";`,
+			`DROP TABLE "attempt_questions";`,
+			`DROP TABLE "attempts";`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "create question_revisions",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "question_revisions" (
+				"question_id" INTEGER NOT NULL,
+				"number" INTEGER NOT NULL,
+				"user_id" INTEGER NOT NULL,
+				"created_at" TIMESTAMPTZ NOT NULL,
+				"snapshot" TEXT NOT NULL,
+				PRIMARY KEY ("question_id", "number"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "question_revisions";`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "add questions.deleted_at for the trash",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "deleted_at" TIMESTAMPTZ;`,
+			`CREATE INDEX "questions_deleted" ON "questions" ("deleted_at");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_deleted";`,
+			`ALTER TABLE "questions" DROP COLUMN "deleted_at";`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "add questions.version for optimistic concurrency control",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "version";`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "add created_at and updated_at to users, questions and options and the author of questions",
+		Up: []string{
+			`ALTER TABLE "users" ADD COLUMN "created_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`ALTER TABLE "users" ADD COLUMN "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`ALTER TABLE "questions" ADD COLUMN "created_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`ALTER TABLE "questions" ADD COLUMN "created_by" INTEGER;`,
+			`ALTER TABLE "questions" ADD COLUMN "updated_by" INTEGER;`,
+			`UPDATE "questions" SET "created_at" = COALESCE(
+				(SELECT MIN("created_at") FROM "question_revisions" WHERE "question_id" = "questions"."id"),
+				"updated_at"
+			), "created_by" = "user_id", "updated_by" = "user_id";`,
+			`ALTER TABLE "options" ADD COLUMN "created_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`ALTER TABLE "options" ADD COLUMN "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now();`,
+			`UPDATE "options" SET "created_at" = "questions"."created_at", "updated_at" = "questions"."updated_at"
+				FROM "questions" WHERE "questions"."id" = "options"."question_id";`,
+		},
+		Down: []string{
+			`ALTER TABLE "options" DROP COLUMN "updated_at";`,
+			`ALTER TABLE "options" DROP COLUMN "created_at";`,
+			`ALTER TABLE "questions" DROP COLUMN "updated_by";`,
+			`ALTER TABLE "questions" DROP COLUMN "created_by";`,
+			`ALTER TABLE "questions" DROP COLUMN "created_at";`,
+			`ALTER TABLE "users" DROP COLUMN "updated_at";`,
+			`ALTER TABLE "users" DROP COLUMN "created_at";`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "create question_shares for sharing questions with collaborators",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "question_shares" (
+				"question_id" INTEGER NOT NULL,
+				"user_id" INTEGER NOT NULL,
+				"role" TEXT NOT NULL,
+				"created_at" TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY ("question_id", "user_id"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "question_shares_user" ON "question_shares" ("user_id", "question_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "question_shares";`,
+		},
+	},
+}
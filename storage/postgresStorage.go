@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"database/sql"
+	_ "github.com/lib/pq" // driver for postgres
+)
+
+// PostgresStorage object to access a PostgreSQL database
+type PostgresStorage struct {
+	sqlStorage
+}
+
+// NewPostgresStorage connects to the PostgreSQL database at url and automatically applies pending migrations
+// Passwords are hashed with passwords
+// If the database was migrated by a newer version of the app it will result in ErrSchemaTooNew
+func NewPostgresStorage(url string, passwords PasswordHasher) (*PostgresStorage, error) {
+	migrator, err := NewPostgresMigrator(url)
+	if err != nil {
+		return nil, err
+	}
+	err = migrator.Up(migrator.Latest())
+	if err != nil {
+		return nil, err
+	}
+	s := &PostgresStorage{sqlStorage{DB: migrator.DB, Passwords: passwords, dialect: postgresDialect{}}}
+	err = s.backfillRevisions()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewPostgresMigrator connects to the PostgreSQL database at url without applying any migrations
+func NewPostgresMigrator(url string) (*Migrator, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: postgresMigrations, dialect: postgresDialect{}}, nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import "github.com/makupi/backend-homework/models"
+
+// restoreRevision replaces the question with the snapshot of revision number through s, storing a new revision
+// Options of the snapshot that were deleted since are added again with new IDs
+func restoreRevision(s Storage, questionID, number, userID int) (models.Question, error) {
+	revision, err := s.GetRevision(questionID, number, userID)
+	if err != nil {
+		return models.Question{}, err
+	}
+	current, err := s.Get(questionID, userID)
+	if err != nil {
+		return models.Question{}, err
+	}
+	existing := make(map[int]bool, len(current.Options))
+	for _, option := range current.Options {
+		existing[option.ID] = true
+	}
+	snapshot := revision.Question
+	snapshot.Version = 0
+	snapshot.Options = make([]models.Option, len(revision.Question.Options))
+	for i, option := range revision.Question.Options {
+		if !existing[option.ID] {
+			option.ID = 0
+		}
+		snapshot.Options[i] = option
+	}
+	return s.Update(questionID, userID, snapshot)
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"github.com/makupi/backend-homework/models"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Search results highlight matches with these markers in their snippet
+const (
+	snippetStart = "<mark>"
+	snippetEnd   = "</mark>"
+)
+
+// The databases mark matches with these private use characters, markSnippet replaces them after escaping the text
+const (
+	matchStart = "\uE000"
+	matchEnd   = "\uE001"
+)
+
+// markSnippet HTML-escapes a snippet marked with matchStart and matchEnd and highlights the matches
+func markSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer(matchStart, snippetStart, matchEnd, snippetEnd).Replace(snippet)
+}
+
+// matchQuery renders every term as FTS5 phrase, terms are implicitly combined with AND
+// Words only consist of letters and digits, so they can't contain any FTS syntax
+func (sqliteDialect) matchQuery(query models.SearchQuery) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		terms[i] = `"` + strings.Join(term.Words, " ") + `"`
+		if term.Prefix {
+			terms[i] += " *"
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func (sqliteDialect) searchFilter() string {
+	return `id IN (SELECT rowid FROM questions_fts WHERE questions_fts MATCH (?))`
+}
+
+// searchRanks ranks with the built-in BM25 of FTS5, matches in the question body count double
+// bm25 returns lower values for better matches, so it is negated
+func (sqliteDialect) searchRanks(q querier, query, conditions string, args []interface{}) ([]models.Question, error) {
+	rows, err := q.Query(
+		`SELECT questions.id, -bm25(questions_fts, 2.0, 1.0) FROM questions_fts
+		JOIN questions ON questions.id = questions_fts.rowid
+		WHERE questions_fts MATCH (?) AND `+conditions,
+		append([]interface{}{query}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var questions []models.Question
+	for rows.Next() {
+		var question models.Question
+		if err := rows.Scan(&question.ID, &question.Rank); err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+func (sqliteDialect) snippets(n int) string {
+	return `SELECT rowid, snippet(questions_fts, -1, '` + matchStart + `', '` + matchEnd + `', '…', 15) FROM questions_fts
+		WHERE questions_fts MATCH (?) AND rowid IN (` + placeholders(n) + `)`
+}
+
+// matchQuery renders terms as tsquery, phrases use <-> and prefixes :*
+// Words only consist of letters and digits, so they can't contain any tsquery syntax
+func (postgresDialect) matchQuery(query models.SearchQuery) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		terms[i] = "(" + strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			terms[i] += ":*"
+		}
+		terms[i] += ")"
+	}
+	return strings.Join(terms, " & ")
+}
+
+func (postgresDialect) searchFilter() string {
+	return `search @@ to_tsquery('simple', ?)`
+}
+
+func (postgresDialect) searchRanks(q querier, query, conditions string, args []interface{}) ([]models.Question, error) {
+	args = append(append([]interface{}{query}, args...), query)
+	rows, err := q.Query(
+		`SELECT id, ts_rank_cd(search, to_tsquery('simple', ?)) FROM questions
+		WHERE `+conditions+` AND search @@ to_tsquery('simple', ?)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var questions []models.Question
+	for rows.Next() {
+		var question models.Question
+		if err := rows.Scan(&question.ID, &question.Rank); err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+func (postgresDialect) snippets(n int) string {
+	return `SELECT id, ts_headline(
+			'simple',
+			question || ' ' || COALESCE((SELECT string_agg(option, ' ') FROM options WHERE question_id = questions.id), ''),
+			to_tsquery('simple', ?),
+			'StartSel=` + matchStart + `, StopSel=` + matchEnd + `, MinWords=5, MaxWords=15'
+		) FROM questions WHERE id IN (` + placeholders(n) + `)`
+}
+
+// memoryMatch matches query against question and returns its rank and snippet
+// Every term has to match within the body or a single option, matches in the body count double
+func memoryMatch(question models.Question, query models.SearchQuery) (rank float64, snippet string, ok bool) {
+	fields := []string{question.Body}
+	for _, option := range question.Options {
+		fields = append(fields, option.Body)
+	}
+	matched := make([]bool, len(query.Terms))
+	for i, field := range fields {
+		spans := wordSpans(field)
+		marked := make([]bool, len(spans))
+		hits := 0
+		for t, term := range query.Terms {
+			for start := range spans {
+				if termMatchesAt(term, field, spans, start) {
+					matched[t] = true
+					hits++
+					for w := range term.Words {
+						marked[start+w] = true
+					}
+				}
+			}
+		}
+		weight := 1.0
+		if i == 0 {
+			weight = 2
+		}
+		rank += weight * float64(hits)
+		if hits > 0 && snippet == "" {
+			snippet = highlight(field, spans, marked)
+		}
+	}
+	for _, m := range matched {
+		if !m {
+			return 0, "", false
+		}
+	}
+	return rank, snippet, true
+}
+
+// wordSpans returns the byte offsets of the words of text as split by models.SearchWords
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	return spans
+}
+
+// termMatchesAt checks if the words of text starting at the span start match term
+func termMatchesAt(term models.SearchTerm, text string, spans [][2]int, start int) bool {
+	if start+len(term.Words) > len(spans) {
+		return false
+	}
+	for i, word := range term.Words {
+		span := spans[start+i]
+		actual := strings.ToLower(text[span[0]:span[1]])
+		if i == len(term.Words)-1 && term.Prefix {
+			if !strings.HasPrefix(actual, word) {
+				return false
+			}
+		} else if actual != word {
+			return false
+		}
+	}
+	return true
+}
+
+// highlight HTML-escapes text and wraps its marked words in snippetStart and snippetEnd
+func highlight(text string, spans [][2]int, marked []bool) string {
+	var b strings.Builder
+	last := 0
+	for i, span := range spans {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:span[0]]))
+		b.WriteString(snippetStart + html.EscapeString(text[span[0]:span[1]]) + snippetEnd)
+		last = span[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+)
+
+// attemptColumns are the columns of attempts read by scanAttempt
+const attemptColumns = `id, user_id, test_id, candidate, status, time_limit, pass_threshold, started_at, expires_at, submitted_at`
+
+// scanAttempt scans attemptColumns, its questions and answers are not loaded
+func scanAttempt(row scanner) (models.Attempt, error) {
+	var attempt models.Attempt
+	var testID sql.NullInt64
+	var expiresAt, submittedAt sql.NullTime
+	err := row.Scan(
+		&attempt.ID,
+		&attempt.AuthorID,
+		&testID,
+		&attempt.Candidate,
+		&attempt.Status,
+		&attempt.TimeLimit,
+		&attempt.PassThreshold,
+		&attempt.StartedAt,
+		&expiresAt,
+		&submittedAt,
+	)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	attempt.TestID = int(testID.Int64)
+	attempt.StartedAt = attempt.StartedAt.UTC()
+	if expiresAt.Valid {
+		t := expiresAt.Time.UTC()
+		attempt.ExpiresAt = &t
+	}
+	if submittedAt.Valid {
+		t := submittedAt.Time.UTC()
+		attempt.SubmittedAt = &t
+	}
+	return attempt, nil
+}
+
+// getAttempt returns the stored attempt id with its questions and answers
+// Expired attempts are returned as they are stored, use attempt to close them first
+func (s *sqlStorage) getAttempt(id int) (models.Attempt, error) {
+	row := s.db().QueryRow(`SELECT `+attemptColumns+` FROM attempts WHERE id = (?)`+s.lockRows(), id)
+	attempt, err := scanAttempt(row)
+	if err == sql.ErrNoRows {
+		return models.Attempt{}, notFound("attempt", id)
+	}
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	attempts := []models.Attempt{attempt}
+	err = s.loadAttempts(attempts)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempts[0], nil
+}
+
+// loadAttempts sets the questions, their points and snapshots and the answers of attempts and calculates their score
+// Questions and answers of all attempts are loaded with one query each
+func (s *sqlStorage) loadAttempts(attempts []models.Attempt) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Attempt, len(attempts))
+	args := make([]interface{}, len(attempts))
+	for i := range attempts {
+		attempt := &attempts[i]
+		attempt.QuestionIDs, attempt.Points, attempt.Questions = []int{}, []int{}, []models.Question{}
+		attempt.Answers = []models.AttemptAnswer{}
+		byID[attempt.ID] = attempt
+		args[i] = attempt.ID
+	}
+	err := s.getAttemptQuestions(byID, args)
+	if err != nil {
+		return err
+	}
+	err = s.getAttemptAnswers(byID, args)
+	if err != nil {
+		return err
+	}
+	for i := range attempts {
+		attempts[i].Calculate()
+	}
+	return nil
+}
+
+// getAttemptQuestions sets the questions of the attempts with the IDs ids, their points and snapshots in order
+func (s *sqlStorage) getAttemptQuestions(attempts map[int]*models.Attempt, ids []interface{}) error {
+	rows, err := s.db().Query(
+		`SELECT attempt_id, question_id, points, snapshot FROM attempt_questions
+		WHERE attempt_id IN (`+placeholders(len(ids))+`) ORDER BY attempt_id, position`,
+		ids...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var attemptID, id, points int
+		var snapshot string
+		if err := rows.Scan(&attemptID, &id, &points, &snapshot); err != nil {
+			return err
+		}
+		var question models.Question
+		if err := json.Unmarshal([]byte(snapshot), &question); err != nil {
+			return err
+		}
+		attempt := attempts[attemptID]
+		attempt.QuestionIDs = append(attempt.QuestionIDs, id)
+		attempt.Points = append(attempt.Points, points)
+		attempt.Questions = append(attempt.Questions, question)
+	}
+	return rows.Err()
+}
+
+// getAttemptAnswers sets the answers of the attempts with the IDs ids in the order of their questions
+func (s *sqlStorage) getAttemptAnswers(attempts map[int]*models.Attempt, ids []interface{}) error {
+	rows, err := s.db().Query(
+		`SELECT a.attempt_id, a.question_id, a.option_ids, a.answer, a.value, a.answered_at, a.correct, a.points
+		FROM attempt_answers a
+		JOIN attempt_questions q ON q.attempt_id = a.attempt_id AND q.question_id = a.question_id
+		WHERE a.attempt_id IN (`+placeholders(len(ids))+`) ORDER BY a.attempt_id, q.position`,
+		ids...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var attemptID int
+		var answer models.AttemptAnswer
+		var optionIDs string
+		var value sql.NullFloat64
+		err := rows.Scan(
+			&attemptID,
+			&answer.QuestionID,
+			&optionIDs,
+			&answer.Answer,
+			&value,
+			&answer.AnsweredAt,
+			&answer.Correct,
+			&answer.Points,
+		)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(optionIDs), &answer.OptionIDs); err != nil {
+			return err
+		}
+		if value.Valid {
+			answer.Value = &value.Float64
+		}
+		answer.AnsweredAt = answer.AnsweredAt.UTC()
+		attempts[attemptID].Answers = append(attempts[attemptID].Answers, answer)
+	}
+	return rows.Err()
+}
+
+// attempt returns the attempt id, if its time ran out it is closed as expired first
+func (s *sqlStorage) attempt(id int) (attempt models.Attempt, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		attempt, err = s.getAttempt(id)
+		if err != nil {
+			return err
+		}
+		return s.expire(&attempt)
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempt, nil
+}
+
+// expire closes attempt as expired if its time ran out, otherwise it is left as it is
+func (s *sqlStorage) expire(attempt *models.Attempt) error {
+	if !attempt.Expired(timestamp()) {
+		return nil
+	}
+	attempt.Finish(models.AttemptExpired, *attempt.ExpiresAt)
+	_, err := s.db().Exec(
+		`UPDATE attempts SET status = (?), submitted_at = (?) WHERE id = (?)`,
+		attempt.Status,
+		attempt.SubmittedAt,
+		attempt.ID,
+	)
+	return err
+}
+
+// StartAttempt starts a new attempt of userID for a test or a set of questions
+// If the test or a question doesn't belong to userID it will result in an error
+func (s *sqlStorage) StartAttempt(userID int, request models.StartAttemptRequest) (started models.Attempt, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		attempt, err := newAttempt(s, userID, request)
+		if err != nil {
+			return err
+		}
+		var testID interface{}
+		if attempt.TestID != 0 {
+			testID = attempt.TestID
+		}
+		id, err := s.insert(
+			`INSERT INTO attempts (user_id, test_id, candidate, status, time_limit, pass_threshold, started_at, expires_at)
+			values (?,?,?,?,?,?,?,?)`,
+			userID,
+			testID,
+			attempt.Candidate,
+			attempt.Status,
+			attempt.TimeLimit,
+			attempt.PassThreshold,
+			attempt.StartedAt,
+			attempt.ExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+		for position, questionID := range attempt.QuestionIDs {
+			snapshot, err := json.Marshal(attempt.Questions[position])
+			if err != nil {
+				return err
+			}
+			_, err = s.db().Exec(
+				`INSERT INTO attempt_questions (attempt_id, question_id, position, points, snapshot) values (?,?,?,?,?)`,
+				id,
+				questionID,
+				position,
+				attempt.Points[position],
+				string(snapshot),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		started, err = s.getAttempt(id)
+		return err
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return started, nil
+}
+
+// ListAttempts returns all attempts started by userID in the order they were started
+// Attempts whose time ran out are closed as expired first
+func (s *sqlStorage) ListAttempts(userID int) (attempts []models.Attempt, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		rows, err := s.db().Query(`SELECT `+attemptColumns+` FROM attempts WHERE user_id = (?) ORDER BY id`+s.lockRows(), userID)
+		if err != nil {
+			return err
+		}
+		attempts = []models.Attempt{}
+		for rows.Next() {
+			attempt, err := scanAttempt(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			attempts = append(attempts, attempt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		err = s.loadAttempts(attempts)
+		if err != nil {
+			return err
+		}
+		for i := range attempts {
+			err = s.expire(&attempts[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetAttempt returns an attempt by ID, will only return attempts started by userID
+// If the attempt doesn't exist or wasn't started by userID it will result in ErrNotFound or ErrForbidden
+func (s *sqlStorage) GetAttempt(id, userID int) (models.Attempt, error) {
+	attempt, err := s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	if attempt.AuthorID != userID {
+		return models.Attempt{}, forbidden("attempt", id)
+	}
+	return attempt, nil
+}
+
+// CandidateAttempt returns an attempt by ID for its candidate
+func (s *sqlStorage) CandidateAttempt(id int) (models.Attempt, error) {
+	return s.attempt(id)
+}
+
+// AnswerAttempt records and grades the answer to the current question of the attempt id
+// If the attempt is closed it will result in ErrAttemptClosed
+func (s *sqlStorage) AnswerAttempt(id int, request models.AnswerRequest) (attempt models.Attempt, err error) {
+	_, err = s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		attempt, err = s.getAttempt(id)
+		if err != nil {
+			return err
+		}
+		answer, err := gradeAnswer(s, attempt, request)
+		if err != nil {
+			return err
+		}
+		optionIDs, err := json.Marshal(answer.OptionIDs)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(
+			`INSERT INTO attempt_answers (attempt_id, question_id, option_ids, answer, value, answered_at, correct, points)
+			values (?,?,?,?,?,?,?,?)`,
+			id,
+			answer.QuestionID,
+			string(optionIDs),
+			answer.Answer,
+			answer.Value,
+			answer.AnsweredAt,
+			answer.Correct,
+			answer.Points,
+		)
+		if err != nil {
+			return err
+		}
+		attempt, err = s.getAttempt(id)
+		return err
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempt, nil
+}
+
+// SubmitAttempt closes the attempt id, questions that weren't answered get no points
+// If the attempt is already closed it will result in ErrAttemptClosed
+func (s *sqlStorage) SubmitAttempt(id int) (attempt models.Attempt, err error) {
+	_, err = s.attempt(id)
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		attempt, err = s.getAttempt(id)
+		if err != nil {
+			return err
+		}
+		submittedAt := timestamp()
+		if attempt.Status != models.AttemptInProgress || attempt.Expired(submittedAt) {
+			return fmt.Errorf("%w: attempt %d", ErrAttemptClosed, id)
+		}
+		attempt.Finish(models.AttemptSubmitted, submittedAt)
+		_, err = s.db().Exec(
+			`UPDATE attempts SET status = (?), submitted_at = (?) WHERE id = (?)`,
+			attempt.Status,
+			attempt.SubmittedAt,
+			id,
+		)
+		return err
+	})
+	if err != nil {
+		return models.Attempt{}, err
+	}
+	return attempt, nil
+}
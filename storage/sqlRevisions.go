@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/makupi/backend-homework/models"
+	"time"
+)
+
+// saveRevision returns the question id and stores it as a new revision made by userID
+// The question may be in the trash, access has to be checked by the caller
+func (s *sqlStorage) saveRevision(id, userID int) (models.Question, error) {
+	question, err := s.question(id)
+	if err != nil {
+		return models.Question{}, err
+	}
+	err = s.insertRevision(question, userID, timestamp())
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// question returns the question id no matter who it belongs to or if it is in the trash
+func (s *sqlStorage) question(id int) (models.Question, error) {
+	questions, err := s.queryQuestions(`SELECT `+questionColumns+` FROM questions WHERE id = (?)`, id)
+	if err != nil {
+		return models.Question{}, err
+	}
+	if len(questions) == 0 {
+		return models.Question{}, notFound("question", id)
+	}
+	err = s.loadRelations(questions)
+	if err != nil {
+		return models.Question{}, err
+	}
+	return questions[0], nil
+}
+
+// insertRevision stores question as its next revision made by userID at createdAt
+func (s *sqlStorage) insertRevision(question models.Question, userID int, createdAt time.Time) error {
+	snapshot, err := json.Marshal(question)
+	if err != nil {
+		return err
+	}
+	var number int
+	row := s.db().QueryRow(`SELECT COALESCE(MAX(number), 0) + 1 FROM question_revisions WHERE question_id = (?)`, question.ID)
+	if err := row.Scan(&number); err != nil {
+		return err
+	}
+	_, err = s.db().Exec(
+		`INSERT INTO question_revisions (question_id, number, user_id, created_at, snapshot) values (?,?,?,?,?)`,
+		question.ID,
+		number,
+		userID,
+		createdAt,
+		string(snapshot),
+	)
+	return err
+}
+
+// backfillRevisions stores the current state of every question without revisions as its first revision,
+// made by the last author at the time of the last change
+// Questions created before revisions were recorded would otherwise have no revision to go back to
+func (s *sqlStorage) backfillRevisions() error {
+	return s.atomic(func(s *sqlStorage) error {
+		questions, err := s.queryQuestions(
+			`SELECT ` + questionColumns + ` FROM questions
+			WHERE NOT EXISTS (SELECT 1 FROM question_revisions WHERE question_id = questions.id) ORDER BY id`,
+		)
+		if err != nil || len(questions) == 0 {
+			return err
+		}
+		err = s.loadRelations(questions)
+		if err != nil {
+			return err
+		}
+		for _, question := range questions {
+			err = s.insertRevision(question, question.UpdatedBy, question.UpdatedAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// touchQuestions marks the questions ids as updated by userID, increments their version and stores a new revision of each
+func (s *sqlStorage) touchQuestions(ids []int, userID int) error {
+	for _, id := range ids {
+		err := s.touch(id, userID)
+		if err != nil {
+			return err
+		}
+		_, err = s.saveRevision(id, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRevision scans a row of question_id, number, user_id, created_at and snapshot
+func scanRevision(row scanner) (models.Revision, error) {
+	var revision models.Revision
+	var snapshot string
+	err := row.Scan(&revision.QuestionID, &revision.Number, &revision.AuthorID, &revision.CreatedAt, &snapshot)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	revision.CreatedAt = revision.CreatedAt.UTC()
+	err = json.Unmarshal([]byte(snapshot), &revision.Question)
+	return revision, err
+}
+
+// ListRevisions returns every revision of a question, oldest first
+// If the question doesn't belong to userID it will result in an error
+func (s *sqlStorage) ListRevisions(questionID, userID int) ([]models.Revision, error) {
+	_, err := s.Get(questionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db().Query(
+		`SELECT question_id, number, user_id, created_at, snapshot FROM question_revisions WHERE question_id = (?) ORDER BY number`,
+		questionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []models.Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns a single revision of a question
+// If the question doesn't belong to userID or the revision doesn't exist it will result in an error
+func (s *sqlStorage) GetRevision(questionID, number, userID int) (models.Revision, error) {
+	_, err := s.Get(questionID, userID)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	row := s.db().QueryRow(
+		`SELECT question_id, number, user_id, created_at, snapshot FROM question_revisions WHERE question_id = (?) AND number = (?)`,
+		questionID,
+		number,
+	)
+	revision, err := scanRevision(row)
+	if err == sql.ErrNoRows {
+		return models.Revision{}, notFound("revision", number)
+	}
+	if err != nil {
+		return models.Revision{}, err
+	}
+	return revision, nil
+}
+
+// RestoreRevision replaces a question with the snapshot of one of its revisions, storing a new revision
+// If the question doesn't belong to userID or the revision doesn't exist it will result in an error
+func (s *sqlStorage) RestoreRevision(questionID, number, userID int) (restored models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		restored, err = restoreRevision(s, questionID, number, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return restored, nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+)
+
+// shareColumns are the columns of question_shares joined with users read by scanShare
+const shareColumns = `question_shares.question_id, question_shares.user_id, users.username, question_shares.role,
+	question_shares.created_at`
+
+// scanShare scans a row of shareColumns
+func scanShare(row scanner) (models.Share, error) {
+	var share models.Share
+	err := row.Scan(&share.QuestionID, &share.UserID, &share.Username, &share.Role, &share.CreatedAt)
+	return share, err
+}
+
+// ListShares returns the users questionID is shared with sorted by username
+// If userID has no role on the question it will result in an error
+func (s *sqlStorage) ListShares(questionID, userID int) ([]models.Share, error) {
+	_, err := s.Get(questionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db().Query(
+		`SELECT `+shareColumns+` FROM question_shares JOIN users ON users.id = question_shares.user_id
+		WHERE question_shares.question_id = (?) ORDER BY users.username`,
+		questionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := []models.Share{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// ShareQuestion grants the user request.Username request.Role on questionID, replacing the role they had before
+// If userID isn't an owner of the question, the user doesn't exist or created the question it will result in an error
+func (s *sqlStorage) ShareQuestion(questionID, userID int, request models.ShareRequest) (share models.Share, err error) {
+	err = request.Validate()
+	if err != nil {
+		return models.Share{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		question, err := s.get(questionID, userID, models.RoleOwner, false)
+		if err != nil {
+			return err
+		}
+		var sharedUserID int
+		err = s.db().QueryRow(`SELECT id FROM users WHERE username = (?)`, request.Username).Scan(&sharedUserID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user %q %w", request.Username, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if sharedUserID == question.CreatedBy {
+			return fmt.Errorf("user %q as owner of question %d %w", request.Username, questionID, ErrConflict)
+		}
+		_, err = s.db().Exec(
+			`INSERT INTO question_shares (question_id, user_id, role, created_at) VALUES (?,?,?,?)
+			ON CONFLICT (question_id, user_id) DO UPDATE SET role = excluded.role`,
+			questionID,
+			sharedUserID,
+			request.Role,
+			timestamp(),
+		)
+		if err != nil {
+			return err
+		}
+		row := s.db().QueryRow(
+			`SELECT `+shareColumns+` FROM question_shares JOIN users ON users.id = question_shares.user_id
+			WHERE question_shares.question_id = (?) AND question_shares.user_id = (?)`,
+			questionID,
+			sharedUserID,
+		)
+		share, err = scanShare(row)
+		return err
+	})
+	if err != nil {
+		return models.Share{}, err
+	}
+	return share, nil
+}
+
+// RevokeShare removes the role of sharedUserID on questionID and removes the question from their tests
+// Owners can revoke every share, everyone else only their own
+// If the question isn't shared with sharedUserID it will result in ErrNotFound
+func (s *sqlStorage) RevokeShare(questionID, sharedUserID, userID int) error {
+	return s.atomic(func(s *sqlStorage) error {
+		role := models.RoleOwner
+		if sharedUserID == userID {
+			role = models.RoleViewer
+		}
+		_, err := s.get(questionID, userID, role, false)
+		if err != nil {
+			return err
+		}
+		result, err := s.db().Exec(
+			`DELETE FROM question_shares WHERE question_id = (?) AND user_id = (?)`,
+			questionID,
+			sharedUserID,
+		)
+		if err != nil {
+			return err
+		}
+		revoked, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if revoked == 0 {
+			return fmt.Errorf("share of question %d with user %d %w", questionID, sharedUserID, ErrNotFound)
+		}
+		_, err = s.db().Exec(
+			`DELETE FROM test_questions WHERE question_id = (?) AND test_id IN (SELECT id FROM tests WHERE user_id = (?))`,
+			questionID,
+			sharedUserID,
+		)
+		return err
+	})
+}
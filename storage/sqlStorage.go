@@ -0,0 +1,1114 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"log"
+	"strings"
+	"time"
+)
+
+// sqlStorage implements Storage on top of database/sql, SqliteStorage and PostgresStorage embed it
+// All queries use ? placeholders which are rewritten by the dialect of the database
+// tx is only set for the sqlStorage passed to Atomic
+type sqlStorage struct {
+	DB        *sql.DB
+	Passwords PasswordHasher
+	dialect   dialect
+	tx        *sql.Tx
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// db returns the transaction if s is used within Atomic and the database otherwise
+// Placeholders of all queries run through it are rewritten for the dialect
+func (s *sqlStorage) db() querier {
+	if s.tx != nil {
+		return rebinder{s.tx, s.dialect}
+	}
+	return rebinder{s.DB, s.dialect}
+}
+
+// rebinder rewrites the placeholders of every query before passing it on to q
+type rebinder struct {
+	q       querier
+	dialect dialect
+}
+
+func (r rebinder) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.q.Exec(r.dialect.rebind(query), args...)
+}
+
+func (r rebinder) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.q.Query(r.dialect.rebind(query), args...)
+}
+
+func (r rebinder) QueryRow(query string, args ...interface{}) *sql.Row {
+	return r.q.QueryRow(r.dialect.rebind(query), args...)
+}
+
+// Atomic runs fn within a single transaction, fn must only use the Storage it is given
+// If fn returns an error or panics the transaction is rolled back, otherwise it is committed
+// Calling Atomic within fn reuses the running transaction
+func (s *sqlStorage) Atomic(fn func(tx Storage) error) error {
+	return s.atomic(func(s *sqlStorage) error {
+		return fn(s)
+	})
+}
+
+func (s *sqlStorage) atomic(fn func(s *sqlStorage) error) (err error) {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	err = fn(&sqlStorage{DB: s.DB, Passwords: s.Passwords, dialect: s.dialect, tx: tx})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// insert runs an INSERT statement and returns the id of the new row
+func (s *sqlStorage) insert(query string, args ...interface{}) (int, error) {
+	id, err := s.dialect.insert(s.db(), query, args...)
+	return int(id), err
+}
+
+// lockRows returns the clause that locks selected rows until the transaction ends
+// Outside of Atomic no rows are locked
+func (s *sqlStorage) lockRows() string {
+	if s.tx == nil {
+		return ""
+	}
+	return s.dialect.lockRows()
+}
+
+// getOptions returns the options of questionID in order
+func (s *sqlStorage) getOptions(questionID int) ([]models.Option, error) {
+	options, err := s.getOptionsFor([]int{questionID})
+	return options[questionID], err
+}
+
+// getOptionsFor loads the options of all questionIDs with a single query and returns them by question ID
+func (s *sqlStorage) getOptionsFor(questionIDs []int) (map[int][]models.Option, error) {
+	options := make(map[int][]models.Option, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return options, nil
+	}
+	args := make([]interface{}, len(questionIDs))
+	for i, id := range questionIDs {
+		args[i] = id
+		options[id] = []models.Option{}
+	}
+	rows, err := s.db().Query(
+		`SELECT id, question_id, option, correct, created_at, updated_at FROM options WHERE question_id IN (`+placeholders(len(args))+`)
+		ORDER BY question_id, position, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var option models.Option
+		err := rows.Scan(&option.ID, &option.QuestionID, &option.Body, &option.Correct, &option.CreatedAt, &option.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		option.Position = len(options[option.QuestionID])
+		options[option.QuestionID] = append(options[option.QuestionID], option)
+	}
+	return options, rows.Err()
+}
+
+// getAcceptedAnswersFor loads the accepted answers of all questionIDs with a single query and returns them by question ID
+func (s *sqlStorage) getAcceptedAnswersFor(questionIDs []int) (map[int][]models.AcceptedAnswer, error) {
+	answers := make(map[int][]models.AcceptedAnswer, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return answers, nil
+	}
+	args := make([]interface{}, len(questionIDs))
+	for i, id := range questionIDs {
+		args[i] = id
+	}
+	rows, err := s.db().Query(
+		`SELECT question_id, answer, regex, case_sensitive FROM accepted_answers
+		WHERE question_id IN (`+placeholders(len(args))+`) ORDER BY question_id, position`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID int
+		var answer models.AcceptedAnswer
+		if err := rows.Scan(&questionID, &answer.Answer, &answer.Regex, &answer.CaseSensitive); err != nil {
+			return nil, err
+		}
+		answers[questionID] = append(answers[questionID], answer)
+	}
+	return answers, rows.Err()
+}
+
+// setAcceptedAnswers replaces the accepted answers of questionID with answers
+func (s *sqlStorage) setAcceptedAnswers(questionID int, answers []models.AcceptedAnswer) error {
+	_, err := s.db().Exec(`DELETE FROM accepted_answers WHERE question_id = (?)`, questionID)
+	if err != nil {
+		return err
+	}
+	for position, answer := range answers {
+		_, err = s.db().Exec(
+			`INSERT INTO accepted_answers (question_id, answer, regex, case_sensitive, position) values (?,?,?,?,?)`,
+			questionID,
+			answer.Answer,
+			answer.Regex,
+			answer.CaseSensitive,
+			position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRelations loads the options, accepted answers and tags of questions with one query each
+func (s *sqlStorage) loadRelations(questions []models.Question) error {
+	ids := make([]int, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	options, err := s.getOptionsFor(ids)
+	if err != nil {
+		return err
+	}
+	answers, err := s.getAcceptedAnswersFor(ids)
+	if err != nil {
+		return err
+	}
+	tags, err := s.getTagsFor(ids)
+	if err != nil {
+		return err
+	}
+	for i := range questions {
+		questions[i].Options = options[questions[i].ID]
+		questions[i].AcceptedAnswers = answers[questions[i].ID]
+		questions[i].Tags = tags[questions[i].ID]
+	}
+	return nil
+}
+
+// placeholders returns n comma separated placeholders for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// List returns the requested page of the questions userID has a role on
+// Searches only include matching questions and set their snippet, tags filter according to page.TagMode
+func (s *sqlStorage) List(userID int, page models.PageRequest) (models.QuestionPage, error) {
+	conditions := `(user_id = (?) OR id IN (SELECT question_id FROM question_shares WHERE user_id = (?))) AND deleted_at IS NULL`
+	conditionArgs := []interface{}{userID, userID}
+	if len(page.Tags) > 0 {
+		condition, args := tagFilter(page)
+		conditions, conditionArgs = conditions+condition, append(conditionArgs, args...)
+	}
+	condition, args := metadataFilter(page)
+	conditions, conditionArgs = conditions+condition, append(conditionArgs, args...)
+	filter, args := conditions, append([]interface{}{}, conditionArgs...)
+	var match string
+	if page.Query != nil {
+		match = s.dialect.matchQuery(*page.Query)
+		filter, args = filter+` AND `+s.dialect.searchFilter(), append(args, match)
+	}
+	var total int
+	err := s.db().QueryRow(`SELECT COUNT(*) FROM questions WHERE `+filter, args...).Scan(&total)
+	if err != nil {
+		return models.QuestionPage{}, err
+	}
+	var questions []models.Question
+	if page.Sort == models.SortRelevance && page.Query != nil {
+		questions, err = s.listByRelevance(match, conditions, conditionArgs, page)
+	} else {
+		where, pageArgs, order := pageClauses(page)
+		args = append(append(args, pageArgs...), page.Limit+1)
+		questions, err = s.queryQuestions(
+			`SELECT `+questionColumns+` FROM questions WHERE `+filter+where+` ORDER BY `+order+` LIMIT (?)`,
+			args...,
+		)
+	}
+	if err != nil {
+		return models.QuestionPage{}, err
+	}
+	err = s.loadRelations(questions)
+	if err != nil {
+		return models.QuestionPage{}, err
+	}
+	if page.Query != nil && len(questions) > 0 {
+		err = s.setSnippets(questions, match)
+		if err != nil {
+			return models.QuestionPage{}, err
+		}
+	}
+	return models.NewQuestionPage(page, questions, total), nil
+}
+
+// questionColumns are the columns of questions read by scanQuestion
+const questionColumns = `id, question, shuffle_options, updated_at, difficulty, time_limit, points, explanation, type,
+	numeric_value, numeric_tolerance, deleted_at, version, created_at, created_by, updated_by`
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQuestion scans questionColumns followed by extra columns into extra
+func scanQuestion(row scanner, extra ...interface{}) (models.Question, error) {
+	var question models.Question
+	var value, tolerance sql.NullFloat64
+	var deletedAt sql.NullTime
+	err := row.Scan(append([]interface{}{
+		&question.ID,
+		&question.Body,
+		&question.ShuffleOptions,
+		&question.UpdatedAt,
+		&question.Difficulty,
+		&question.TimeLimit,
+		&question.Points,
+		&question.Explanation,
+		&question.Type,
+		&value,
+		&tolerance,
+		&deletedAt,
+		&question.Version,
+		&question.CreatedAt,
+		&question.CreatedBy,
+		&question.UpdatedBy,
+	}, extra...)...)
+	if value.Valid {
+		question.NumericAnswer = &models.NumericAnswer{Value: value.Float64, Tolerance: tolerance.Float64}
+	}
+	if deletedAt.Valid {
+		deleted := deletedAt.Time.UTC()
+		question.DeletedAt = &deleted
+	}
+	return question, err
+}
+
+// numericColumns returns the numeric_value and numeric_tolerance to store for question, NULL if it has no NumericAnswer
+func numericColumns(question models.Question) (value, tolerance interface{}) {
+	if question.NumericAnswer == nil {
+		return nil, nil
+	}
+	return question.NumericAnswer.Value, question.NumericAnswer.Tolerance
+}
+
+// queryQuestions runs query which has to select questionColumns
+// Options of the questions are not loaded
+func (s *sqlStorage) queryQuestions(query string, args ...interface{}) ([]models.Question, error) {
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	questions := []models.Question{}
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// metadataFilter returns the condition on questions selecting the difficulties, points, time limit and
+// last change of page
+func metadataFilter(page models.PageRequest) (condition string, args []interface{}) {
+	if len(page.Difficulties) > 0 {
+		condition += ` AND difficulty IN (` + placeholders(len(page.Difficulties)) + `)`
+		for _, difficulty := range page.Difficulties {
+			args = append(args, difficulty)
+		}
+	}
+	if page.MinPoints > 0 {
+		condition, args = condition+` AND points >= (?)`, append(args, page.MinPoints)
+	}
+	if page.MaxPoints > 0 {
+		condition, args = condition+` AND points <= (?)`, append(args, page.MaxPoints)
+	}
+	if page.MaxTimeLimit > 0 {
+		condition, args = condition+` AND time_limit BETWEEN 1 AND (?)`, append(args, page.MaxTimeLimit)
+	}
+	if !page.UpdatedSince.IsZero() {
+		condition, args = condition+` AND updated_at >= (?)`, append(args, page.UpdatedSince.UTC())
+	}
+	return condition, args
+}
+
+// listByRelevance returns the questions matching the rendered search query match and conditions for page
+// All matches are ranked, the page is cut out of them with fetchPage
+func (s *sqlStorage) listByRelevance(match, conditions string, args []interface{}, page models.PageRequest) ([]models.Question, error) {
+	ranked, err := s.dialect.searchRanks(s.db(), match, conditions, args)
+	if err != nil {
+		return nil, err
+	}
+	ranked = fetchPage(ranked, page)
+	if len(ranked) == 0 {
+		return ranked, nil
+	}
+	ids := make([]interface{}, len(ranked))
+	for i, question := range ranked {
+		ids[i] = question.ID
+	}
+	questions, err := s.queryQuestions(
+		`SELECT `+questionColumns+` FROM questions WHERE id IN (`+placeholders(len(ids))+`)`,
+		ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.Question, len(questions))
+	for _, question := range questions {
+		byID[question.ID] = question
+	}
+	for i, question := range ranked {
+		rank := question.Rank
+		ranked[i] = byID[question.ID]
+		ranked[i].Rank = rank
+	}
+	return ranked, nil
+}
+
+// setSnippets sets the snippet of every question for the rendered search query match
+func (s *sqlStorage) setSnippets(questions []models.Question, match string) error {
+	args := []interface{}{match}
+	for _, question := range questions {
+		args = append(args, question.ID)
+	}
+	rows, err := s.db().Query(s.dialect.snippets(len(questions)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	snippets := make(map[int]string, len(questions))
+	for rows.Next() {
+		var id int
+		var snippet string
+		if err := rows.Scan(&id, &snippet); err != nil {
+			return err
+		}
+		snippets[id] = markSnippet(snippet)
+	}
+	for i := range questions {
+		questions[i].Snippet = snippets[questions[i].ID]
+	}
+	return rows.Err()
+}
+
+// pageClauses returns the condition selecting the questions after the cursor of page and the order to fetch them in
+// Pages before the cursor are fetched in reverse order
+func pageClauses(page models.PageRequest) (where string, args []interface{}, order string) {
+	descending := page.Sort != models.SortOldest
+	if page.Cursor != nil && page.Cursor.Before {
+		descending = !descending
+	}
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+	cursor := page.Cursor
+	if page.Sort == models.SortUpdated {
+		order = "updated_at " + direction + ", id " + direction
+		if cursor != nil {
+			where = " AND (updated_at " + comparison + " (?) OR (updated_at = (?) AND id " + comparison + " (?)))"
+			args = []interface{}{cursor.UpdatedAt.UTC(), cursor.UpdatedAt.UTC(), cursor.ID}
+		}
+		return
+	}
+	order = "id " + direction
+	if cursor != nil {
+		where = " AND id " + comparison + " (?)"
+		args = []interface{}{cursor.ID}
+	}
+	return
+}
+
+// AddOption adds an Option to an existing question
+// If userID isn't at least an editor of the question or the result is not a valid question it will result in an error
+func (s *sqlStorage) AddOption(option models.Option, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		question.Options = append(question.Options, option)
+		err = question.Validate()
+		if err != nil {
+			return err
+		}
+		err = s.addOptions([]models.Option{option}, questionID, s.nextOptionPosition(questionID))
+		if err != nil {
+			return err
+		}
+		err = s.touch(questionID, userID)
+		if err != nil {
+			return err
+		}
+		question, err = s.saveRevision(questionID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// nextOptionPosition returns the position after the last option of questionID
+func (s *sqlStorage) nextOptionPosition(questionID int) int {
+	var position int
+	row := s.db().QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM options WHERE question_id = (?)`, questionID)
+	if err := row.Scan(&position); err != nil {
+		log.Print(err)
+	}
+	return position
+}
+
+// addOptions inserts options in order, the first one at position
+func (s *sqlStorage) addOptions(options []models.Option, questionID, position int) error {
+	now := timestamp()
+	for i, option := range options {
+		_, err := s.db().Exec(
+			`INSERT INTO options (question_id, option, correct, position, created_at, updated_at) values (?,?,?,?,?,?)`,
+			questionID,
+			option.Body,
+			option.Correct,
+			position+i,
+			now,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add a new Question associated to the userID
+// If question is not valid it will result in an error
+func (s *sqlStorage) Add(userID int, question models.Question) (q models.Question, err error) {
+	question = question.WithDefaults()
+	err = question.Validate()
+	if err != nil {
+		return q, err
+	}
+	value, tolerance := numericColumns(question)
+	err = s.atomic(func(s *sqlStorage) error {
+		now := timestamp()
+		id, err := s.insert(
+			`INSERT INTO questions (question, user_id, shuffle_options, updated_at, difficulty, time_limit, points, explanation,
+			type, numeric_value, numeric_tolerance, created_at, created_by, updated_by) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+			question.Body,
+			userID,
+			question.ShuffleOptions,
+			now,
+			question.Difficulty,
+			question.TimeLimit,
+			question.Points,
+			question.Explanation,
+			question.Type,
+			value,
+			tolerance,
+			now,
+			userID,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		err = s.addOptions(question.Options, int(id), 0)
+		if err != nil {
+			return err
+		}
+		err = s.setAcceptedAnswers(int(id), question.AcceptedAnswers)
+		if err != nil {
+			return err
+		}
+		err = s.setTags(int(id), userID, models.NormalizeTags(question.Tags))
+		if err != nil {
+			return err
+		}
+		q, err = s.saveRevision(int(id), userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return q, nil
+}
+
+// Get a question by ID, will only return questions userID has at least the viewer role on
+// If the question doesn't exist, is deleted or userID has no role on it it will result in ErrNotFound or ErrForbidden
+func (s *sqlStorage) Get(id, userID int) (models.Question, error) {
+	return s.get(id, userID, models.RoleViewer, false)
+}
+
+// get returns the question id if userID has at least role on it, deleted says if it has to be in the trash or must not be
+// The user who created the question is always its owner
+func (s *sqlStorage) get(id, userID int, role string, deleted bool) (models.Question, error) {
+	row := s.db().QueryRow(
+		`SELECT `+questionColumns+`, user_id,
+		COALESCE((SELECT role FROM question_shares WHERE question_id = questions.id AND user_id = (?)), '')
+		FROM questions WHERE id = (?)`+s.lockRows(),
+		userID,
+		id,
+	)
+	var owner int
+	var granted string
+	question, err := scanQuestion(row, &owner, &granted)
+	if err == sql.ErrNoRows || err == nil && (question.DeletedAt != nil) != deleted {
+		return models.Question{}, notFound("question", id)
+	}
+	if err != nil {
+		return models.Question{}, err
+	}
+	if owner == userID {
+		granted = models.RoleOwner
+	}
+	if granted == "" {
+		return models.Question{}, forbidden("question", id)
+	}
+	if !models.RoleAllows(granted, role) {
+		return models.Question{}, requiresRole(id, role)
+	}
+	questions := []models.Question{question}
+	err = s.loadRelations(questions)
+	if err != nil {
+		return models.Question{}, err
+	}
+	return questions[0], nil
+}
+
+// touch marks the question id as updated now by userID and increments its version
+func (s *sqlStorage) touch(id, userID int) error {
+	_, err := s.db().Exec(
+		`UPDATE questions SET updated_at = (?), updated_by = (?), version = version + 1 WHERE id = (?)`,
+		timestamp(),
+		userID,
+		id,
+	)
+	return err
+}
+
+// updateQuestion updates the columns and accepted answers of the question id as userID and increments its version
+// If question.Version is set the update only happens if it is still the current version,
+// otherwise it will result in ErrVersionMismatch
+func (s *sqlStorage) updateQuestion(id, userID int, question models.Question) error {
+	value, tolerance := numericColumns(question)
+	result, err := s.db().Exec(
+		`UPDATE questions SET question = (?), shuffle_options = (?), updated_at = (?), difficulty = (?), time_limit = (?),
+		points = (?), explanation = (?), type = (?), numeric_value = (?), numeric_tolerance = (?), updated_by = (?),
+		version = version + 1 WHERE id = (?) AND (version = (?) OR (?) = 0)`,
+		question.Body,
+		question.ShuffleOptions,
+		timestamp(),
+		question.Difficulty,
+		question.TimeLimit,
+		question.Points,
+		question.Explanation,
+		question.Type,
+		value,
+		tolerance,
+		userID,
+		id,
+		question.Version,
+		question.Version,
+	)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("question %d: %w %d", id, ErrVersionMismatch, question.Version)
+	}
+	return s.setAcceptedAnswers(id, question.AcceptedAnswers)
+}
+
+// UpdateOption updates an existing option
+// If userID isn't at least an editor of the question or the result is not a valid question it will result in an error
+func (s *sqlStorage) UpdateOption(option models.Option, optionID, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		found := false
+		for i, currentOption := range question.Options {
+			if currentOption.ID == optionID {
+				question.Options[i] = option
+				found = true
+			}
+		}
+		if !found {
+			return notFound("option", optionID)
+		}
+		err = question.Validate()
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(
+			`UPDATE options SET option = (?), correct = (?), updated_at = (?) WHERE id = (?) AND question_id = (?)`,
+			option.Body,
+			option.Correct,
+			timestamp(),
+			optionID,
+			questionID,
+		)
+		if err != nil {
+			return err
+		}
+		err = s.touch(questionID, userID)
+		if err != nil {
+			return err
+		}
+		question, err = s.saveRevision(questionID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// Update replaces an existing question with question
+// Options with an ID are updated, options without an ID are added and options missing from question are deleted,
+// the order of question.Options is kept
+// If question.Version is set and the question was changed since, it will result in ErrVersionMismatch
+// If userID isn't at least an editor of the question or question is not valid it will result in an error
+func (s *sqlStorage) Update(id, userID int, question models.Question) (updated models.Question, err error) {
+	question = question.WithDefaults()
+	err = question.Validate()
+	if err != nil {
+		return models.Question{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		currentQ, err := s.get(id, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		err = currentQ.ValidateReplacement(question)
+		if err != nil {
+			return err
+		}
+		err = s.updateQuestion(id, userID, question)
+		if err != nil {
+			return err
+		}
+		// tags belong to the user who created the question, no matter who edits it
+		err = s.setTags(id, currentQ.CreatedBy, models.NormalizeTags(question.Tags))
+		if err != nil {
+			return err
+		}
+		kept := make(map[int]bool)
+		for _, option := range question.Options {
+			kept[option.ID] = true
+		}
+		current := make(map[int]models.Option, len(currentQ.Options))
+		for _, currentOption := range currentQ.Options {
+			current[currentOption.ID] = currentOption
+			if !kept[currentOption.ID] {
+				_, err = s.db().Exec(`DELETE FROM options WHERE id = (?) AND question_id = (?)`, currentOption.ID, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for position, option := range question.Options {
+			if option.ID == 0 {
+				err = s.addOptions([]models.Option{option}, id, position)
+			} else {
+				_, err = s.db().Exec(
+					`UPDATE options SET option = (?), correct = (?), position = (?), updated_at = (?) WHERE id = (?) AND question_id = (?)`,
+					option.Body,
+					option.Correct,
+					position,
+					optionUpdatedAt(current[option.ID], option, position),
+					option.ID,
+					id,
+				)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		updated, err = s.saveRevision(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return updated, nil
+}
+
+// DeleteOption deletes an existing option from a question
+// If userID isn't at least an editor of the question, it doesn't exist or the result is not a valid question it will result in an error
+func (s *sqlStorage) DeleteOption(optionID, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		var options []models.Option
+		for _, option := range question.Options {
+			if option.ID != optionID {
+				options = append(options, option)
+			}
+		}
+		if len(options) == len(question.Options) {
+			return notFound("option", optionID)
+		}
+		question.Options = options
+		err = question.Validate()
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`DELETE FROM options WHERE id = (?) AND question_id = (?)`, optionID, questionID)
+		if err != nil {
+			return err
+		}
+		err = s.touch(questionID, userID)
+		if err != nil {
+			return err
+		}
+		question, err = s.saveRevision(questionID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// ReorderOptions changes the order of the options of a question to the order of optionIDs
+// optionIDs must contain every option of the question exactly once, otherwise it will result in an error
+func (s *sqlStorage) ReorderOptions(optionIDs []int, questionID, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		question, err = s.get(questionID, userID, models.RoleEditor, false)
+		if err != nil {
+			return err
+		}
+		err = question.ValidateReorder(optionIDs)
+		if err != nil {
+			return err
+		}
+		current := make(map[int]models.Option, len(question.Options))
+		for _, option := range question.Options {
+			current[option.ID] = option
+		}
+		for position, optionID := range optionIDs {
+			_, err = s.db().Exec(
+				`UPDATE options SET position = (?), updated_at = (?) WHERE id = (?) AND question_id = (?)`,
+				position,
+				optionUpdatedAt(current[optionID], current[optionID], position),
+				optionID,
+				questionID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.touch(questionID, userID)
+		if err != nil {
+			return err
+		}
+		question, err = s.saveRevision(questionID, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// Delete moves an existing question to the trash, storing a new revision, and removes it from all tests
+// If userID isn't an owner of the question or it doesn't exist it will result in an error
+func (s *sqlStorage) Delete(id, userID int) error {
+	return s.atomic(func(s *sqlStorage) error {
+		_, err := s.get(id, userID, models.RoleOwner, false)
+		if err != nil {
+			return err
+		}
+		deletedAt := timestamp()
+		_, err = s.db().Exec(
+			`UPDATE questions SET deleted_at = (?), updated_at = (?), updated_by = (?), version = version + 1 WHERE id = (?)`,
+			deletedAt,
+			deletedAt,
+			userID,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`DELETE FROM test_questions WHERE question_id = (?)`, id)
+		if err != nil {
+			return err
+		}
+		_, err = s.saveRevision(id, userID)
+		return err
+	})
+}
+
+// ListTrash returns the deleted questions userID owns, the most recently deleted first
+func (s *sqlStorage) ListTrash(userID int) ([]models.Question, error) {
+	questions, err := s.queryQuestions(
+		`SELECT `+questionColumns+` FROM questions
+		WHERE (user_id = (?) OR id IN (SELECT question_id FROM question_shares WHERE user_id = (?) AND role = (?)))
+		AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`,
+		userID,
+		userID,
+		models.RoleOwner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = s.loadRelations(questions)
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// RestoreQuestion moves a deleted question out of the trash, it isn't added to the tests it was removed from
+// If the question isn't in the trash or userID isn't an owner of it it will result in an error
+func (s *sqlStorage) RestoreQuestion(id, userID int) (question models.Question, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		_, err := s.get(id, userID, models.RoleOwner, true)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(
+			`UPDATE questions SET deleted_at = NULL, updated_at = (?), updated_by = (?), version = version + 1 WHERE id = (?)`,
+			timestamp(),
+			userID,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+		question, err = s.saveRevision(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Question{}, err
+	}
+	return question, nil
+}
+
+// PurgeDeleted permanently deletes every question that was moved to the trash before before
+// It returns the number of deleted questions
+func (s *sqlStorage) PurgeDeleted(before time.Time) (int, error) {
+	result, err := s.db().Exec(
+		`DELETE FROM questions WHERE deleted_at IS NOT NULL AND deleted_at < (?)`,
+		before.UTC().Truncate(time.Microsecond),
+	)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// CreateUser creates a new user with username and password, only the hash of password is stored
+// If the user already exists it will return an error
+func (s *sqlStorage) CreateUser(username, password string) (models.UserResponse, error) {
+	hash, err := s.Passwords.Hash(password)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	now := timestamp()
+	id, err := s.insert(
+		`INSERT INTO users (username, password, created_at, updated_at) values (?,?,?,?)`,
+		username,
+		hash,
+		now,
+		now,
+	)
+	if s.dialect.isUniqueViolation(err) {
+		return models.UserResponse{}, fmt.Errorf("username %q %w", username, ErrConflict)
+	}
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	return models.UserResponse{ID: id, Username: username, CreatedAt: now, UpdatedAt: now}, nil
+}
+
+// CreateToken creates a new access token and refresh token for the user
+// If username and password are incorrect it will result in ErrInvalidCredentials
+// Legacy plaintext passwords and hashes with an outdated cost are rehashed on success
+func (s *sqlStorage) CreateToken(username, password string, config TokenConfig) (models.JWTTokenResponse, error) {
+	var user models.User
+	row := s.db().QueryRow(`SELECT id, username, password FROM users WHERE username = (?)`, username)
+	err := row.Scan(&user.ID, &user.Username, &user.Password)
+	if err == sql.ErrNoRows {
+		return models.JWTTokenResponse{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	ok, needsRehash := s.Passwords.Verify(user.Password, password)
+	if !ok {
+		return models.JWTTokenResponse{}, ErrInvalidCredentials
+	}
+	if needsRehash {
+		err = s.rehashPassword(user.ID, password)
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	return s.issueTokens(user.ID, config)
+}
+
+// issueTokens persists a new refresh token for userID and returns it together with a new access token
+func (s *sqlStorage) issueTokens(userID int, config TokenConfig) (models.JWTTokenResponse, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	_, err = s.db().Exec(
+		`INSERT INTO refresh_tokens (token_hash, user_id, expires_at) values (?,?,?)`,
+		hashRefreshToken(refreshToken),
+		userID,
+		time.Now().Add(config.RefreshTTL).UTC(),
+	)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return newTokenResponse(userID, refreshToken, config)
+}
+
+// RefreshToken exchanges a valid refresh token for a new access token and refresh token
+// The used refresh token is revoked, if it is unknown, expired or revoked it will result in ErrInvalidToken
+func (s *sqlStorage) RefreshToken(refreshToken string, config TokenConfig) (token models.JWTTokenResponse, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		var id, userID int
+		var expiresAt time.Time
+		var revoked bool
+		row := s.db().QueryRow(
+			`SELECT id, user_id, expires_at, revoked FROM refresh_tokens WHERE token_hash = (?)`+s.lockRows(),
+			hashRefreshToken(refreshToken),
+		)
+		err := row.Scan(&id, &userID, &expiresAt, &revoked)
+		if err == sql.ErrNoRows {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if revoked || time.Now().After(expiresAt) {
+			return ErrInvalidToken
+		}
+		result, err := s.db().Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE id = (?) AND revoked = FALSE`, id)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return ErrInvalidToken
+		}
+		token, err = s.issueTokens(userID, config)
+		return err
+	})
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return token, nil
+}
+
+// RevokeTokens revokes the refresh token of userID and denylists the access token jti until it expires
+func (s *sqlStorage) RevokeTokens(userID int, refreshToken, jti string, expiresAt time.Time) error {
+	return s.atomic(func(s *sqlStorage) error {
+		_, err := s.db().Exec(
+			`UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = (?) AND user_id = (?)`,
+			hashRefreshToken(refreshToken),
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`INSERT INTO revoked_tokens (jti, expires_at) values (?,?) ON CONFLICT (jti) DO NOTHING`, jti, expiresAt.UTC())
+		if err != nil {
+			return err
+		}
+		// expired access tokens are rejected anyway, no need to keep their jti around
+		_, err = s.db().Exec(`DELETE FROM revoked_tokens WHERE expires_at < (?)`, time.Now().UTC())
+		return err
+	})
+}
+
+// IsTokenRevoked checks if the access token with jti has been revoked
+func (s *sqlStorage) IsTokenRevoked(jti string) bool {
+	row := s.db().QueryRow(`SELECT jti FROM revoked_tokens WHERE jti = (?)`, jti)
+	err := row.Scan(&jti)
+	return err == nil
+}
+
+func (s *sqlStorage) rehashPassword(userID int, password string) error {
+	hash, err := s.Passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.db().Exec(`UPDATE users SET password = (?) WHERE id = (?)`, hash, userID)
+	return err
+}
+
+// UserIDExists checks if a given userID exists
+func (s *sqlStorage) UserIDExists(userID int) bool {
+	row := s.db().QueryRow(`SELECT id, username, password FROM users WHERE id = (?)`, userID)
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Password)
+	if err != nil {
+		return false
+	}
+	return true
+}
+
+// HasQuestionAccess verifies that a userID has access to a questionID
+// Returns true if the user has any role on the question and false if not
+func (s *sqlStorage) HasQuestionAccess(userID, questionID int) bool {
+	row := s.db().QueryRow(
+		`SELECT questions.id FROM questions WHERE id = (?) AND deleted_at IS NULL
+		AND (user_id = (?) OR id IN (SELECT question_id FROM question_shares WHERE user_id = (?)))`,
+		questionID,
+		userID,
+		userID,
+	)
+	var question models.Question
+	err := row.Scan(&question.ID)
+	if err != nil {
+		return false
+	}
+	return true
+}
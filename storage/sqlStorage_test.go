@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"golang.org/x/crypto/bcrypt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const benchmarkQuestions = 10000
+
+// newBenchmarkStorage returns a SqliteStorage with benchmarkQuestions tagged questions for a single user,
+// every fourth is a free text question with 2 accepted answers and the others have 4 options
+func newBenchmarkStorage(b *testing.B) (*SqliteStorage, int) {
+	b.Helper()
+	s, err := NewSqliteStorage(filepath.Join(b.TempDir(), "db.sqlite3"), NewPasswordHasher(bcrypt.MinCost))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { s.DB.Close() })
+	user, err := s.CreateUser("benchmark", "password")
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = s.Atomic(func(tx Storage) error {
+		for i := 0; i < benchmarkQuestions; i++ {
+			question := models.Question{
+				Body: fmt.Sprintf("Question %d", i),
+				Options: []models.Option{
+					{Body: "A", Correct: true},
+					{Body: "B"},
+					{Body: "C"},
+					{Body: "D"},
+				},
+				Tags: []string{fmt.Sprintf("tag-%d", i%10)},
+			}
+			if i%4 == 0 {
+				question.Type = models.QuestionTypeFreeText
+				question.Options = nil
+				question.AcceptedAnswers = []models.AcceptedAnswer{{Answer: "A"}, {Answer: "B"}}
+			}
+			_, err := tx.Add(user.ID, question)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return s, user.ID
+}
+
+// listPerQuestion loads the questions of userID before beforeID newest first like List used to,
+// with the same count and filter as List but one query each for the options, accepted answers and tags of every question
+func listPerQuestion(s *SqliteStorage, userID, beforeID, limit int) ([]models.Question, int, error) {
+	filter := `(user_id = (?) OR id IN (SELECT question_id FROM question_shares WHERE user_id = (?))) AND deleted_at IS NULL`
+	var total int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM questions WHERE `+filter, userID, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	questions, err := s.queryQuestions(
+		`SELECT `+questionColumns+` FROM questions WHERE `+filter+` AND id < (?) ORDER BY id DESC LIMIT (?)`,
+		userID,
+		userID,
+		beforeID,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range questions {
+		id := questions[i].ID
+		questions[i].Options, err = s.getOptions(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		answers, err := s.getAcceptedAnswersFor([]int{id})
+		if err != nil {
+			return nil, 0, err
+		}
+		tags, err := s.getTagsFor([]int{id})
+		if err != nil {
+			return nil, 0, err
+		}
+		questions[i].AcceptedAnswers, questions[i].Tags = answers[id], tags[id]
+	}
+	return questions, total, nil
+}
+
+// BenchmarkList pages through all questions, Batched is List itself and
+// PerQuestion loads the relations of every question with their own queries like List used to
+func BenchmarkList(b *testing.B) {
+	s, userID := newBenchmarkStorage(b)
+	// both have to load the same data for the comparison to be fair
+	page, err := s.List(userID, models.PageRequest{Sort: models.SortNewest, Limit: models.MaxPageSize})
+	if err != nil {
+		b.Fatal(err)
+	}
+	questions, total, err := listPerQuestion(s, userID, math.MaxInt32, models.MaxPageSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if total != page.Total || !reflect.DeepEqual(questions, page.Questions) {
+		b.Fatalf("listPerQuestion loaded %d of %d questions, List %d of %d", len(questions), total, len(page.Questions), page.Total)
+	}
+	b.Run("Batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			request := models.PageRequest{Sort: models.SortNewest, Limit: models.MaxPageSize}
+			count := 0
+			for {
+				page, err := s.List(userID, request)
+				if err != nil {
+					b.Fatal(err)
+				}
+				count += len(page.Questions)
+				if page.NextCursor == "" {
+					break
+				}
+				cursor, err := models.DecodeCursor(page.NextCursor, request.Sort)
+				if err != nil {
+					b.Fatal(err)
+				}
+				request.Cursor = &cursor
+			}
+			if count != benchmarkQuestions {
+				b.Fatalf("listed %d questions, want %d", count, benchmarkQuestions)
+			}
+		}
+	})
+	b.Run("PerQuestion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			count := 0
+			beforeID := math.MaxInt32
+			for {
+				questions, _, err := listPerQuestion(s, userID, beforeID, models.MaxPageSize)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(questions) == 0 {
+					break
+				}
+				count += len(questions)
+				beforeID = questions[len(questions)-1].ID
+			}
+			if count != benchmarkQuestions {
+				b.Fatalf("listed %d questions, want %d", count, benchmarkQuestions)
+			}
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+)
+
+// getTagsFor loads the tag names of all questionIDs with a single query and returns them by question ID
+// Every question gets a non-nil slice, even without tags
+func (s *sqlStorage) getTagsFor(questionIDs []int) (map[int][]string, error) {
+	tags := make(map[int][]string, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return tags, nil
+	}
+	args := make([]interface{}, len(questionIDs))
+	for i, id := range questionIDs {
+		args[i] = id
+		tags[id] = []string{}
+	}
+	rows, err := s.db().Query(
+		`SELECT question_tags.question_id, tags.name FROM question_tags JOIN tags ON tags.id = question_tags.tag_id
+		WHERE question_tags.question_id IN (`+placeholders(len(args))+`) ORDER BY tags.name`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID int
+		var name string
+		if err := rows.Scan(&questionID, &name); err != nil {
+			return nil, err
+		}
+		tags[questionID] = append(tags[questionID], name)
+	}
+	return tags, rows.Err()
+}
+
+// setTags replaces the tags of questionID with the normalized names, missing tags are created for userID
+func (s *sqlStorage) setTags(questionID, userID int, names []string) error {
+	_, err := s.db().Exec(`DELETE FROM question_tags WHERE question_id = (?)`, questionID)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	args := []interface{}{questionID, userID}
+	for _, name := range names {
+		_, err = s.db().Exec(`INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT (user_id, name) DO NOTHING`, userID, name)
+		if err != nil {
+			return err
+		}
+		args = append(args, name)
+	}
+	_, err = s.db().Exec(
+		`INSERT INTO question_tags (question_id, tag_id) SELECT CAST(? AS INTEGER), id FROM tags WHERE user_id = (?) AND name IN (`+placeholders(len(names))+`)`,
+		args...,
+	)
+	return err
+}
+
+// tagFilter returns the condition on questions selecting the tags of page
+func tagFilter(page models.PageRequest) (string, []interface{}) {
+	args := make([]interface{}, len(page.Tags))
+	for i, name := range page.Tags {
+		args[i] = name
+	}
+	condition := ` AND id IN (SELECT question_tags.question_id FROM question_tags JOIN tags ON tags.id = question_tags.tag_id
+		WHERE tags.name IN (` + placeholders(len(args)) + `) GROUP BY question_tags.question_id`
+	if page.TagMode != models.TagModeAny {
+		condition += ` HAVING COUNT(*) = (?)`
+		args = append(args, len(args))
+	}
+	return condition + `)`, args
+}
+
+// ListTags returns all tags of userID sorted by name
+func (s *sqlStorage) ListTags(userID int) ([]models.Tag, error) {
+	rows, err := s.db().Query(
+		`SELECT tags.id, tags.name, COUNT(question_tags.question_id) FROM tags
+		LEFT JOIN question_tags ON question_tags.tag_id = tags.id
+			AND question_tags.question_id IN (SELECT id FROM questions WHERE deleted_at IS NULL)
+		WHERE tags.user_id = (?) GROUP BY tags.id, tags.name ORDER BY tags.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.QuestionCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTag returns a tag by ID, will only return tags of userID
+// If the tag doesn't exist or doesn't belong to userID it will result in ErrNotFound or ErrForbidden
+func (s *sqlStorage) GetTag(id, userID int) (models.Tag, error) {
+	var tag models.Tag
+	var _userID int
+	row := s.db().QueryRow(
+		`SELECT tags.id, tags.name, tags.user_id, COUNT(question_tags.question_id) FROM tags
+		LEFT JOIN question_tags ON question_tags.tag_id = tags.id
+			AND question_tags.question_id IN (SELECT id FROM questions WHERE deleted_at IS NULL)
+		WHERE tags.id = (?) GROUP BY tags.id, tags.name, tags.user_id`,
+		id,
+	)
+	err := row.Scan(&tag.ID, &tag.Name, &_userID, &tag.QuestionCount)
+	if err == sql.ErrNoRows {
+		return models.Tag{}, notFound("tag", id)
+	}
+	if err != nil {
+		return models.Tag{}, err
+	}
+	if _userID != userID {
+		return models.Tag{}, forbidden("tag", id)
+	}
+	return tag, nil
+}
+
+// AddTag adds a new tag for userID
+// If tag is not valid or userID already has a tag with the same name it will result in an error
+func (s *sqlStorage) AddTag(userID int, tag models.Tag) (models.Tag, error) {
+	err := tag.Validate()
+	if err != nil {
+		return models.Tag{}, err
+	}
+	name := models.NormalizeTag(tag.Name)
+	id, err := s.insert(`INSERT INTO tags (user_id, name) VALUES (?, ?)`, userID, name)
+	if s.dialect.isUniqueViolation(err) {
+		return models.Tag{}, fmt.Errorf("tag %q %w", name, ErrConflict)
+	}
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return s.GetTag(id, userID)
+}
+
+// UpdateTag renames an existing tag, the questions with the tag keep it and store a new revision
+// If the tag doesn't belong to userID, tag is not valid or the name is taken it will result in an error
+func (s *sqlStorage) UpdateTag(id, userID int, tag models.Tag) (updated models.Tag, err error) {
+	err = tag.Validate()
+	if err != nil {
+		return models.Tag{}, err
+	}
+	name := models.NormalizeTag(tag.Name)
+	err = s.atomic(func(s *sqlStorage) error {
+		_, err := s.GetTag(id, userID)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`UPDATE tags SET name = (?) WHERE id = (?)`, name, id)
+		if s.dialect.isUniqueViolation(err) {
+			return fmt.Errorf("tag %q %w", name, ErrConflict)
+		}
+		if err != nil {
+			return err
+		}
+		tagged, err := s.taggedQuestions(id)
+		if err != nil {
+			return err
+		}
+		err = s.touchQuestions(tagged, userID)
+		if err != nil {
+			return err
+		}
+		updated, err = s.GetTag(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return updated, nil
+}
+
+// DeleteTag deletes an existing tag and removes it from all questions, storing a new revision of each
+// If the tag doesn't exist or doesn't belong to userID it will result in an error
+func (s *sqlStorage) DeleteTag(id, userID int) error {
+	return s.atomic(func(s *sqlStorage) error {
+		_, err := s.GetTag(id, userID)
+		if err != nil {
+			return err
+		}
+		tagged, err := s.taggedQuestions(id)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`DELETE FROM tags WHERE id = (?)`, id)
+		if err != nil {
+			return err
+		}
+		return s.touchQuestions(tagged, userID)
+	})
+}
+
+// taggedQuestions returns the IDs of the questions with the tag id in ascending order
+func (s *sqlStorage) taggedQuestions(id int) ([]int, error) {
+	rows, err := s.db().Query(`SELECT question_id FROM question_tags WHERE tag_id = (?) ORDER BY question_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var questionID int
+		if err := rows.Scan(&questionID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, questionID)
+	}
+	return ids, rows.Err()
+}
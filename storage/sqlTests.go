@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/makupi/backend-homework/models"
+)
+
+// checkQuestions verifies that every question of questionIDs exists and userID has a role on it
+// The first missing, deleted or inaccessible question results in ErrNotFound or ErrForbidden
+func (s *sqlStorage) checkQuestions(userID int, questionIDs []int) error {
+	if len(questionIDs) == 0 {
+		return nil
+	}
+	args := []interface{}{userID, userID}
+	for _, id := range questionIDs {
+		args = append(args, id)
+	}
+	rows, err := s.db().Query(
+		`SELECT id, user_id = (?) OR id IN (SELECT question_id FROM question_shares WHERE user_id = (?)) FROM questions
+		WHERE deleted_at IS NULL AND id IN (`+placeholders(len(questionIDs))+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	accessible := make(map[int]bool, len(questionIDs))
+	for rows.Next() {
+		var id int
+		var access bool
+		if err := rows.Scan(&id, &access); err != nil {
+			return err
+		}
+		accessible[id] = access
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range questionIDs {
+		access, ok := accessible[id]
+		if !ok {
+			return notFound("question", id)
+		}
+		if !access {
+			return forbidden("question", id)
+		}
+	}
+	return nil
+}
+
+// getTestQuestionIDs returns the IDs of the questions of testID in order
+func (s *sqlStorage) getTestQuestionIDs(testID int) ([]int, error) {
+	rows, err := s.db().Query(`SELECT question_id FROM test_questions WHERE test_id = (?) ORDER BY position`, testID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// getTestQuestionIDsFor returns the IDs of the questions of every test of userID in order, mapped by test ID
+func (s *sqlStorage) getTestQuestionIDsFor(userID int) (map[int][]int, error) {
+	rows, err := s.db().Query(
+		`SELECT test_id, question_id FROM test_questions WHERE test_id IN (SELECT id FROM tests WHERE user_id = (?))
+		ORDER BY test_id, position`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[int][]int)
+	for rows.Next() {
+		var testID, id int
+		if err := rows.Scan(&testID, &id); err != nil {
+			return nil, err
+		}
+		ids[testID] = append(ids[testID], id)
+	}
+	return ids, rows.Err()
+}
+
+// setTestQuestions replaces the questions of testID with questionIDs in order
+func (s *sqlStorage) setTestQuestions(testID int, questionIDs []int) error {
+	_, err := s.db().Exec(`DELETE FROM test_questions WHERE test_id = (?)`, testID)
+	if err != nil {
+		return err
+	}
+	for position, id := range questionIDs {
+		_, err = s.db().Exec(`INSERT INTO test_questions (test_id, question_id, position) values (?,?,?)`, testID, id, position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTests returns all tests of userID in the order they were created
+func (s *sqlStorage) ListTests(userID int) ([]models.Test, error) {
+	rows, err := s.db().Query(
+		`SELECT id, title, description, time_limit, pass_threshold FROM tests WHERE user_id = (?) ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	tests := []models.Test{}
+	for rows.Next() {
+		var test models.Test
+		if err := rows.Scan(&test.ID, &test.Title, &test.Description, &test.TimeLimit, &test.PassThreshold); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	questionIDs, err := s.getTestQuestionIDsFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tests {
+		tests[i].QuestionIDs = questionIDs[tests[i].ID]
+		if tests[i].QuestionIDs == nil {
+			tests[i].QuestionIDs = []int{}
+		}
+	}
+	return tests, nil
+}
+
+// GetTest returns a test by ID, will only return tests of userID
+// If the test doesn't exist or doesn't belong to userID it will result in ErrNotFound or ErrForbidden
+func (s *sqlStorage) GetTest(id, userID int) (models.Test, error) {
+	var test models.Test
+	var _userID int
+	row := s.db().QueryRow(
+		`SELECT id, title, description, time_limit, pass_threshold, user_id FROM tests WHERE id = (?)`+s.lockRows(),
+		id,
+	)
+	err := row.Scan(&test.ID, &test.Title, &test.Description, &test.TimeLimit, &test.PassThreshold, &_userID)
+	if err == sql.ErrNoRows {
+		return models.Test{}, notFound("test", id)
+	}
+	if err != nil {
+		return models.Test{}, err
+	}
+	if _userID != userID {
+		return models.Test{}, forbidden("test", id)
+	}
+	test.QuestionIDs, err = s.getTestQuestionIDs(id)
+	if err != nil {
+		return models.Test{}, err
+	}
+	return test, nil
+}
+
+// AddTest adds a new test for userID
+// If test is not valid or contains questions of other users it will result in an error
+func (s *sqlStorage) AddTest(userID int, test models.Test) (added models.Test, err error) {
+	err = test.Validate()
+	if err != nil {
+		return models.Test{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		err := s.checkQuestions(userID, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		id, err := s.insert(
+			`INSERT INTO tests (user_id, title, description, time_limit, pass_threshold) values (?,?,?,?,?)`,
+			userID,
+			test.Title,
+			test.Description,
+			test.TimeLimit,
+			test.PassThreshold,
+		)
+		if err != nil {
+			return err
+		}
+		err = s.setTestQuestions(id, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		added, err = s.GetTest(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return added, nil
+}
+
+// UpdateTest replaces an existing test with test, including the order of its questions
+// If the test doesn't belong to userID, test is not valid or contains questions of other users it will result in an error
+func (s *sqlStorage) UpdateTest(id, userID int, test models.Test) (updated models.Test, err error) {
+	err = test.Validate()
+	if err != nil {
+		return models.Test{}, err
+	}
+	err = s.atomic(func(s *sqlStorage) error {
+		_, err := s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		err = s.checkQuestions(userID, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(
+			`UPDATE tests SET title = (?), description = (?), time_limit = (?), pass_threshold = (?) WHERE id = (?)`,
+			test.Title,
+			test.Description,
+			test.TimeLimit,
+			test.PassThreshold,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+		err = s.setTestQuestions(id, test.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		updated, err = s.GetTest(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return updated, nil
+}
+
+// DeleteTest deletes an existing test, its questions are kept
+// If the test doesn't exist or doesn't belong to userID it will result in an error
+func (s *sqlStorage) DeleteTest(id, userID int) error {
+	return s.atomic(func(s *sqlStorage) error {
+		_, err := s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		_, err = s.db().Exec(`DELETE FROM tests WHERE id = (?)`, id)
+		return err
+	})
+}
+
+// AddTestQuestions appends questions of the library of userID to the test id
+// Questions that are already part of the test keep their position
+// If the test or a question doesn't belong to userID it will result in an error
+func (s *sqlStorage) AddTestQuestions(id, userID int, questionIDs []int) (test models.Test, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		test, err = s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		test.QuestionIDs = appendMissing(test.QuestionIDs, questionIDs)
+		test, err = s.UpdateTest(id, userID, test)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return test, nil
+}
+
+// RemoveTestQuestion removes a question from the test id, the question itself is kept
+// If the test doesn't belong to userID or doesn't contain the question it will result in an error
+func (s *sqlStorage) RemoveTestQuestion(id, questionID, userID int) (test models.Test, err error) {
+	err = s.atomic(func(s *sqlStorage) error {
+		test, err = s.GetTest(id, userID)
+		if err != nil {
+			return err
+		}
+		remaining, ok := removeID(test.QuestionIDs, questionID)
+		if !ok {
+			return notFound("question", questionID)
+		}
+		err = s.setTestQuestions(id, remaining)
+		if err != nil {
+			return err
+		}
+		test, err = s.GetTest(id, userID)
+		return err
+	})
+	if err != nil {
+		return models.Test{}, err
+	}
+	return test, nil
+}
+
+// HasTestAccess verifies that a userID has access to a testID
+func (s *sqlStorage) HasTestAccess(userID, testID int) bool {
+	var id int
+	err := s.db().QueryRow(`SELECT id FROM tests WHERE id = (?) AND user_id = (?)`, testID, userID).Scan(&id)
+	return err == nil
+}
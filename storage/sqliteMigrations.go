@@ -0,0 +1,452 @@
+package storage
+
+// sqliteMigrations are the schema migrations for SqliteStorage
+// users:
+// | id: pkey, int | username: text, unique | password: text | created_at: datetime | updated_at: datetime |
+// questions:
+// | id: pkey, int | body: text | userID: fkey(users.id), int | shuffle_options: bool | updated_at: datetime |
+// | difficulty: text | time_limit: int | points: int | explanation: text | type: text |
+// | numeric_value: real, nullable | numeric_tolerance: real, nullable | deleted_at: datetime, nullable |
+// | version: int | created_at: datetime | created_by: int | updated_by: int |
+// options:
+// | id: pkey, int | body: text | correct: bool | question_id: fkey(questions.id), int | position: int |
+// | created_at: datetime | updated_at: datetime |
+// accepted_answers:
+// | id: pkey, int | question_id: fkey(questions.id), int | answer: text | regex: bool | case_sensitive: bool | position: int |
+// refresh_tokens:
+// | id: pkey, int | token_hash: text, unique | user_id: fkey(users.id), int | expires_at: datetime | revoked: bool |
+// revoked_tokens:
+// | jti: pkey, text | expires_at: datetime |
+// questions_fts, FTS5 index kept in sync by triggers:
+// | rowid: questions.id | body: text | options: text |
+// tags:
+// | id: pkey, int | user_id: fkey(users.id), int | name: text, unique per user |
+// question_tags:
+// | question_id: fkey(questions.id), int | tag_id: fkey(tags.id), int |
+// tests:
+// | id: pkey, int | user_id: fkey(users.id), int | title: text | description: text | time_limit: int | pass_threshold: int |
+// test_questions:
+// | test_id: fkey(tests.id), int | question_id: fkey(questions.id), int | position: int |
+// attempts:
+// | id: pkey, int | user_id: fkey(users.id), int | test_id: int, nullable | candidate: text | status: text |
+// | time_limit: int | pass_threshold: int | started_at: datetime | expires_at: datetime, nullable |
+// | submitted_at: datetime, nullable |
+// attempt_questions:
+// | attempt_id: fkey(attempts.id), int | question_id: int | position: int | points: int | snapshot: text, json |
+// attempt_answers:
+// | attempt_id: fkey(attempts.id), int | question_id: int | option_ids: text, json | answer: text |
+// | value: real, nullable | answered_at: datetime | correct: bool | points: real |
+// question_revisions:
+// | question_id: fkey(questions.id), int | number: int | user_id: int | created_at: datetime | snapshot: text, json |
+// question_shares:
+// | question_id: fkey(questions.id), int | user_id: fkey(users.id), int | role: text | created_at: datetime |
+var sqliteMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, questions and options",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "users" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"username" TEXT NOT NULL UNIQUE,
+				"password" TEXT
+			);`,
+			`CREATE TABLE IF NOT EXISTS "questions" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"question" TEXT,
+				"user_id" INTEGER NOT NULL,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "options" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"question_id" INTEGER NOT NULL,
+				"option" TEXT,
+				"correct" BOOLEAN,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "options";`,
+			`DROP TABLE "questions";`,
+			`DROP TABLE "users";`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create refresh_tokens and revoked_tokens",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"token_hash" TEXT NOT NULL UNIQUE,
+				"user_id" INTEGER NOT NULL,
+				"expires_at" DATETIME NOT NULL,
+				"revoked" BOOLEAN NOT NULL DEFAULT 0,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+				"jti" TEXT NOT NULL PRIMARY KEY,
+				"expires_at" DATETIME NOT NULL
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "revoked_tokens";`,
+			`DROP TABLE "refresh_tokens";`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add options.position and questions.shuffle_options",
+		Up: []string{
+			`ALTER TABLE "options" ADD COLUMN "position" INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE "questions" ADD COLUMN "shuffle_options" BOOLEAN NOT NULL DEFAULT 0;`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "shuffle_options";`,
+			`ALTER TABLE "options" DROP COLUMN "position";`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add questions.updated_at for sorting by last update",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "updated_at" DATETIME;`,
+			// same format the driver uses for time.Time, so existing rows compare correctly against new ones
+			`UPDATE "questions" SET "updated_at" = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');`,
+			`CREATE INDEX "questions_user_updated" ON "questions" ("user_id", "updated_at", "id");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_user_updated";`,
+			`ALTER TABLE "questions" DROP COLUMN "updated_at";`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "index options by question and questions by user",
+		Up: []string{
+			`CREATE INDEX "options_question_position" ON "options" ("question_id", "position", "id");`,
+			`CREATE INDEX "questions_user" ON "questions" ("user_id", "id");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_user";`,
+			`DROP INDEX "options_question_position";`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add full-text search over questions and options",
+		Up: []string{
+			`CREATE VIRTUAL TABLE "questions_fts" USING fts5("body", "options", tokenize='unicode61');`,
+			`INSERT INTO "questions_fts" ("rowid", "body", "options")
+				SELECT "id", "question", (SELECT group_concat("option", ' ') FROM "options" WHERE "question_id" = "questions"."id")
+				FROM "questions";`,
+			`CREATE TRIGGER "questions_fts_insert" AFTER INSERT ON "questions" BEGIN
+				INSERT INTO "questions_fts" ("rowid", "body", "options") VALUES (NEW."id", NEW."question", '');
+			END;`,
+			`CREATE TRIGGER "questions_fts_update" AFTER UPDATE OF "question" ON "questions" BEGIN
+				UPDATE "questions_fts" SET "body" = NEW."question" WHERE "rowid" = NEW."id";
+			END;`,
+			`CREATE TRIGGER "questions_fts_delete" AFTER DELETE ON "questions" BEGIN
+				DELETE FROM "questions_fts" WHERE "rowid" = OLD."id";
+			END;`,
+			`CREATE TRIGGER "options_fts_insert" AFTER INSERT ON "options" BEGIN
+				UPDATE "questions_fts" SET "options" = (SELECT group_concat("option", ' ') FROM "options" WHERE "question_id" = NEW."question_id")
+				WHERE "rowid" = NEW."question_id";
+			END;`,
+			`CREATE TRIGGER "options_fts_update" AFTER UPDATE OF "option" ON "options" BEGIN
+				UPDATE "questions_fts" SET "options" = (SELECT group_concat("option", ' ') FROM "options" WHERE "question_id" = NEW."question_id")
+				WHERE "rowid" = NEW."question_id";
+			END;`,
+			`CREATE TRIGGER "options_fts_delete" AFTER DELETE ON "options" BEGIN
+				UPDATE "questions_fts" SET "options" = (SELECT group_concat("option", ' ') FROM "options" WHERE "question_id" = OLD."question_id")
+				WHERE "rowid" = OLD."question_id";
+			END;`,
+		},
+		Down: []string{
+			`DROP TRIGGER "options_fts_delete";`,
+			`DROP TRIGGER "options_fts_update";`,
+			`DROP TRIGGER "options_fts_insert";`,
+			`DROP TRIGGER "questions_fts_delete";`,
+			`DROP TRIGGER "questions_fts_update";`,
+			`DROP TRIGGER "questions_fts_insert";`,
+			`DROP TABLE "questions_fts";`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "create tags and question_tags",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "tags" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"user_id" INTEGER NOT NULL,
+				"name" TEXT NOT NULL,
+				UNIQUE ("user_id", "name"),
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "question_tags" (
+				"question_id" INTEGER NOT NULL,
+				"tag_id" INTEGER NOT NULL,
+				PRIMARY KEY ("question_id", "tag_id"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_tag_id
+					FOREIGN KEY (tag_id)
+					REFERENCES tags(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "question_tags_tag" ON "question_tags" ("tag_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "question_tags";`,
+			`DROP TABLE "tags";`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "add difficulty, time limit, points and explanation to questions",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "difficulty" TEXT NOT NULL DEFAULT 'medium';`,
+			`ALTER TABLE "questions" ADD COLUMN "time_limit" INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE "questions" ADD COLUMN "points" INTEGER NOT NULL DEFAULT 1;`,
+			`ALTER TABLE "questions" ADD COLUMN "explanation" TEXT NOT NULL DEFAULT '';`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "explanation";`,
+			`ALTER TABLE "questions" DROP COLUMN "points";`,
+			`ALTER TABLE "questions" DROP COLUMN "time_limit";`,
+			`ALTER TABLE "questions" DROP COLUMN "difficulty";`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "add question types with accepted answers and numeric answers",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "type" TEXT NOT NULL DEFAULT 'multiple_choice';`,
+			`ALTER TABLE "questions" ADD COLUMN "numeric_value" REAL;`,
+			`ALTER TABLE "questions" ADD COLUMN "numeric_tolerance" REAL;`,
+			`CREATE TABLE IF NOT EXISTS "accepted_answers" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"question_id" INTEGER NOT NULL,
+				"answer" TEXT NOT NULL,
+				"regex" BOOLEAN NOT NULL DEFAULT 0,
+				"case_sensitive" BOOLEAN NOT NULL DEFAULT 0,
+				"position" INTEGER NOT NULL DEFAULT 0,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "accepted_answers_question_position" ON "accepted_answers" ("question_id", "position");`,
+		},
+		Down: []string{
+			`DROP TABLE "accepted_answers";`,
+			`ALTER TABLE "questions" DROP COLUMN "numeric_tolerance";`,
+			`ALTER TABLE "questions" DROP COLUMN "numeric_value";`,
+			`ALTER TABLE "questions" DROP COLUMN "type";`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "create tests and test_questions",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "tests" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"user_id" INTEGER NOT NULL,
+				"title" TEXT NOT NULL,
+				"description" TEXT NOT NULL DEFAULT '',
+				"time_limit" INTEGER NOT NULL DEFAULT 0,
+				"pass_threshold" INTEGER NOT NULL DEFAULT 0,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "test_questions" (
+				"test_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"position" INTEGER NOT NULL,
+				PRIMARY KEY ("test_id", "question_id"),
+				CONSTRAINT fk_test_id
+					FOREIGN KEY (test_id)
+					REFERENCES tests(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "tests_user" ON "tests" ("user_id", "id");`,
+			`CREATE INDEX "test_questions_question" ON "test_questions" ("question_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "test_questions";`,
+			`DROP TABLE "tests";`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "create attempts, attempt_questions and attempt_answers",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "attempts" (
+				"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				"user_id" INTEGER NOT NULL,
+				"test_id" INTEGER,
+				"candidate" TEXT NOT NULL DEFAULT '',
+				"status" TEXT NOT NULL,
+				"time_limit" INTEGER NOT NULL DEFAULT 0,
+				"pass_threshold" INTEGER NOT NULL DEFAULT 0,
+				"started_at" DATETIME NOT NULL,
+				"expires_at" DATETIME,
+				"submitted_at" DATETIME,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "attempt_questions" (
+				"attempt_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"position" INTEGER NOT NULL,
+				"points" INTEGER NOT NULL,
+				"snapshot" TEXT NOT NULL,
+				PRIMARY KEY ("attempt_id", "position"),
+				CONSTRAINT fk_attempt_id
+					FOREIGN KEY (attempt_id)
+					REFERENCES attempts(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE TABLE IF NOT EXISTS "attempt_answers" (
+				"attempt_id" INTEGER NOT NULL,
+				"question_id" INTEGER NOT NULL,
+				"option_ids" TEXT NOT NULL DEFAULT '[]',
+				"answer" TEXT NOT NULL DEFAULT '',
+				"value" REAL,
+				"answered_at" DATETIME NOT NULL,
+				"correct" BOOLEAN NOT NULL DEFAULT 0,
+				"points" REAL NOT NULL DEFAULT 0,
+				PRIMARY KEY ("attempt_id", "question_id"),
+				CONSTRAINT fk_attempt_id
+					FOREIGN KEY (attempt_id)
+					REFERENCES attempts(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "attempts_user" ON "attempts" ("user_id", "id");`,
+		},
+		Down: []string{
+			`DROP TABLE "attempt_answers";`,
+			`DROP TABLE "attempt_questions";`,
+			`DROP TABLE "attempts";`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "create question_revisions",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "question_revisions" (
+				"question_id" INTEGER NOT NULL,
+				"number" INTEGER NOT NULL,
+				"user_id" INTEGER NOT NULL,
+				"created_at" DATETIME NOT NULL,
+				"snapshot" TEXT NOT NULL,
+				PRIMARY KEY ("question_id", "number"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE
+			);`,
+		},
+		Down: []string{
+			`DROP TABLE "question_revisions";`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "add questions.deleted_at for the trash",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "deleted_at" DATETIME;`,
+			`CREATE INDEX "questions_deleted" ON "questions" ("deleted_at");`,
+		},
+		Down: []string{
+			`DROP INDEX "questions_deleted";`,
+			`ALTER TABLE "questions" DROP COLUMN "deleted_at";`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "add questions.version for optimistic concurrency control",
+		Up: []string{
+			`ALTER TABLE "questions" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;`,
+		},
+		Down: []string{
+			`ALTER TABLE "questions" DROP COLUMN "version";`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "add created_at and updated_at to users, questions and options and the author of questions",
+		Up: []string{
+			`ALTER TABLE "users" ADD COLUMN "created_at" DATETIME;`,
+			`ALTER TABLE "users" ADD COLUMN "updated_at" DATETIME;`,
+			`UPDATE "users" SET "created_at" = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');`,
+			`UPDATE "users" SET "updated_at" = "created_at";`,
+			`ALTER TABLE "questions" ADD COLUMN "created_at" DATETIME;`,
+			`ALTER TABLE "questions" ADD COLUMN "created_by" INTEGER;`,
+			`ALTER TABLE "questions" ADD COLUMN "updated_by" INTEGER;`,
+			`UPDATE "questions" SET "created_at" = COALESCE(
+				(SELECT MIN("created_at") FROM "question_revisions" WHERE "question_id" = "questions"."id"),
+				"updated_at"
+			), "created_by" = "user_id", "updated_by" = "user_id";`,
+			`ALTER TABLE "options" ADD COLUMN "created_at" DATETIME;`,
+			`ALTER TABLE "options" ADD COLUMN "updated_at" DATETIME;`,
+			`UPDATE "options" SET
+				"created_at" = (SELECT "created_at" FROM "questions" WHERE "id" = "options"."question_id"),
+				"updated_at" = (SELECT "updated_at" FROM "questions" WHERE "id" = "options"."question_id");`,
+		},
+		Down: []string{
+			`ALTER TABLE "options" DROP COLUMN "updated_at";`,
+			`ALTER TABLE "options" DROP COLUMN "created_at";`,
+			`ALTER TABLE "questions" DROP COLUMN "updated_by";`,
+			`ALTER TABLE "questions" DROP COLUMN "created_by";`,
+			`ALTER TABLE "questions" DROP COLUMN "created_at";`,
+			`ALTER TABLE "users" DROP COLUMN "updated_at";`,
+			`ALTER TABLE "users" DROP COLUMN "created_at";`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "create question_shares for sharing questions with collaborators",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "question_shares" (
+				"question_id" INTEGER NOT NULL,
+				"user_id" INTEGER NOT NULL,
+				"role" TEXT NOT NULL,
+				"created_at" DATETIME NOT NULL,
+				PRIMARY KEY ("question_id", "user_id"),
+				CONSTRAINT fk_question_id
+					FOREIGN KEY (question_id)
+					REFERENCES questions(id)
+					ON DELETE CASCADE,
+				CONSTRAINT fk_user_id
+					FOREIGN KEY (user_id)
+					REFERENCES users(id)
+					ON DELETE CASCADE
+			);`,
+			`CREATE INDEX "question_shares_user" ON "question_shares" ("user_id", "question_id");`,
+		},
+		Down: []string{
+			`DROP TABLE "question_shares";`,
+		},
+	},
+}
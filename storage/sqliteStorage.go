@@ -2,324 +2,78 @@ package storage
 
 import (
 	"database/sql"
-	"fmt"
-	"github.com/dgrijalva/jwt-go"
-	"github.com/makupi/backend-homework/models"
+	"errors"
 	_ "github.com/mattn/go-sqlite3" // driver for sqlite3
-	"log"
 )
 
-// SqliteStorage object to access database
+// ErrNoFTS5 is returned when go-sqlite3 was built without the sqlite_fts5 tag, search requires FTS5
+var ErrNoFTS5 = errors.New("SQLite was built without FTS5, build with -tags sqlite_fts5")
+
+// SqliteStorage object to access a SQLite database
 type SqliteStorage struct {
-	DB *sql.DB
+	sqlStorage
 }
 
-// NewSqliteStorage Creates a local db.sqlite3 database and automaticlly creates tables
-func NewSqliteStorage() *SqliteStorage {
-	db, err := sql.Open("sqlite3", "./db.sqlite3?_foreign_keys=on")
+// NewSqliteStorage opens the SQLite database at path and automatically applies pending migrations
+// Passwords are hashed with passwords
+// If the database was migrated by a newer version of the app it will result in ErrSchemaTooNew
+func NewSqliteStorage(path string, passwords PasswordHasher) (*SqliteStorage, error) {
+	migrator, err := NewSqliteMigrator(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	storage := SqliteStorage{DB: db}
-	err = storage.createTables()
+	err = migrator.Up(migrator.Latest())
 	if err != nil {
-		log.Fatal(err)
-	}
-	return &storage
-}
-
-// createTables creates tables necessary database tables
-// users:
-// | id: pkey, int | username: text, unique | password: text |
-// questions:
-// | id: pkey, int | body: text | userID: fkey(users.id), int |
-// options:
-// | id: pkey, int | body: text | correct: bool | question_id: fkey(questions.id), int |
-func (s *SqliteStorage) createTables() error {
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS "users" (
-			"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-			"username" TEXT NOT NULL UNIQUE,
-			"password" TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS "questions" (
-			"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-			"question" TEXT,
-			"user_id" INTEGER NOT NULL,
-			CONSTRAINT fk_user_id
-				FOREIGN KEY (user_id)
-				REFERENCES users(id)
-				ON DELETE CASCADE
-		);`,
-		`CREATE TABLE IF NOT EXISTS "options" (
-			"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-			"question_id" INTEGER NOT NULL,
-			"option" TEXT,
-			"correct" BOOLEAN,
-			CONSTRAINT fk_question_id
-				FOREIGN KEY (question_id)
-				REFERENCES questions(id)
-				ON DELETE CASCADE
-		);`,
-	}
-
-	for _, table := range tables {
-		_, err := s.DB.Exec(table)
-		if err != nil {
-			return err
-		}
+		return nil, err
 	}
-	return nil
-}
-
-func (s *SqliteStorage) getOptions(questionID int) (options []models.Option) {
-	rows, err := s.DB.Query(`SELECT * FROM options WHERE question_id == (?)`, questionID)
+	s := &SqliteStorage{sqlStorage{DB: migrator.DB, Passwords: passwords, dialect: sqliteDialect{}}}
+	err = s.backfillRevisions()
 	if err != nil {
-		log.Print(err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var option models.Option
-		if err := rows.Scan(&option.ID, &option.QuestionID, &option.Body, &option.Correct); err != nil {
-			log.Print(err)
-		}
-		options = append(options, option)
-	}
-	return
-}
-
-// List returns all questions that belong to the userID
-func (s *SqliteStorage) List(userID, lastID, limit int) (questions []models.Question) {
-	var rows *sql.Rows
-	var err error
-	if (lastID != 0) && (limit != 0) {
-		rows, err = s.DB.Query(
-			`SELECT * FROM questions WHERE user_id == (?) AND id < (?) ORDER BY id DESC LIMIT (?)`,
-			userID,
-			lastID,
-			limit,
-		)
-		if err != nil {
-			log.Print(err)
-		}
-	} else {
-		rows, err = s.DB.Query(`SELECT * FROM questions WHERE user_id == (?)`, userID)
-		if err != nil {
-			log.Print(err)
-		}
+		return nil, err
 	}
-
-	defer rows.Close()
-	questions = []models.Question{}
-	for rows.Next() {
-		var question models.Question
-		var _userID int
-		if err := rows.Scan(&question.ID, &question.Body, &_userID); err != nil {
-			log.Print(err)
-		}
-		question.Options = s.getOptions(question.ID)
-		questions = append(questions, question)
-	}
-	return
+	return s, nil
 }
 
-// AddOption adds an Option to an existing question
-// If the question doesn't belong to userID it will result in an error
-func (s *SqliteStorage) AddOption(option models.Option, questionID, userID int) (models.Question, error) {
-	var question models.Question
-	if !s.HasQuestionAccess(userID, questionID) {
-		return question, fmt.Errorf("unauthorized")
-	}
-	_, err := s.DB.Exec(
-		`INSERT INTO options (question_id, option, correct) values (?,?,?)`,
-		questionID,
-		option.Body,
-		option.Correct,
-	)
+// NewSqliteMigrator opens the SQLite database at path without applying any migrations
+// Transactions take the write lock immediately and wait up to 5 seconds for other writers
+// If SQLite was built without FTS5 it will result in ErrNoFTS5
+func NewSqliteMigrator(path string) (*Migrator, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
-		return question, err
+		return nil, err
 	}
-	return s.Get(questionID, userID)
-}
-
-func (s *SqliteStorage) addOptions(options []models.Option, questionID int) error {
-	for _, option := range options {
-		_, err := s.DB.Exec(
-			`INSERT INTO options (question_id, option, correct) values (?,?,?)`,
-			questionID,
-			option.Body,
-			option.Correct,
-		)
-		if err != nil {
-			return err
+	var fts5 bool
+	err = db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	if err != nil || !fts5 {
+		db.Close()
+		if err == nil {
+			err = ErrNoFTS5
 		}
+		return nil, err
 	}
-	return nil
+	return &Migrator{DB: db, Migrations: sqliteMigrations, dialect: sqliteDialect{}, baseline: func() (int, error) {
+		return sqliteBaseline(db)
+	}}, nil
 }
 
-// Add a new Question associated to the userID
-func (s *SqliteStorage) Add(userID int, question models.Question) (models.Question, error) {
-	var q models.Question
-	result, err := s.DB.Exec(`INSERT INTO questions (question, user_id) values (?, ?)`, question.Body, userID)
-	if err != nil {
-		return q, err
-	}
-	id, err := result.LastInsertId()
+// sqliteBaseline returns the version of databases created before migrations were introduced
+// Those databases already have every column added up to migration 3
+func sqliteBaseline(db *sql.DB) (int, error) {
+	rows, err := db.Query(`PRAGMA table_info("questions")`)
 	if err != nil {
-		return q, err
+		return 0, err
 	}
-	err = s.addOptions(question.Options, int(id))
-	if err != nil {
-		return q, err
-	}
-	return s.Get(int(id), userID)
-}
-
-// Get a question by ID, will only return questions associated to the userID
-func (s *SqliteStorage) Get(id, userID int) (models.Question, error) {
-	row := s.DB.QueryRow(`SELECT * FROM questions WHERE id == (?) AND user_id == (?)`, id, userID)
-	var question models.Question
-	var _userID int
-	err := row.Scan(&question.ID, &question.Body, &_userID)
-	if err != nil {
-		return question, err
-	}
-	question.Options = s.getOptions(question.ID)
-	return question, nil
-}
-
-func (s *SqliteStorage) updateQuestion(id, userID int, question models.Question) error {
-	_, err := s.DB.Exec(`UPDATE questions SET question = (?) WHERE id == (?) AND user_id == (?)`, question.Body, id, userID)
-	return err
-}
-
-// UpdateOption updates an existing option
-// If the question doesn't belong to userID it will result in an error
-func (s *SqliteStorage) UpdateOption(option models.Option, optionID, questionID, userID int) (models.Question, error) {
-	var question models.Question
-	if !s.HasQuestionAccess(userID, questionID) {
-		return question, fmt.Errorf("unauthorized")
-	}
-	_, err := s.DB.Exec(
-		`UPDATE options SET option = (?), correct = (?) WHERE id == (?) AND question_id == (?)`,
-		option.Body,
-		option.Correct,
-		optionID,
-		questionID,
-	)
-	if err != nil {
-		return question, err
-	}
-	return s.Get(questionID, userID)
-}
-
-// Update updates an existing question
-// If the question doesn't belong to userID it will result in an error
-func (s *SqliteStorage) Update(id, userID int, question models.Question) (models.Question, error) {
-	currentQ, err := s.Get(id, userID)
-	if err != nil {
-		return models.Question{}, err
-	}
-	if currentQ.Body != question.Body {
-		err = s.updateQuestion(id, userID, question)
-		if err != nil {
-			return models.Question{}, err
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return 0, err
 		}
-	}
-	for _, option := range question.Options {
-		for _, currentOption := range currentQ.Options {
-			if option.ID == currentOption.ID {
-				if (option.Body != currentOption.Body) || (option.Correct != currentOption.Correct) {
-					_, err := s.DB.Exec(
-						`UPDATE options SET option = (?), correct = (?) WHERE id == (?) AND question_id == (?)`,
-						option.Body,
-						option.Correct,
-						option.ID,
-						id,
-					)
-					if err != nil {
-						return models.Question{}, err
-					}
-				}
-			}
+		if name == "shuffle_options" {
+			return 3, nil
 		}
 	}
-	return s.Get(id, userID)
-}
-
-// DeleteOption deletes an existing option from a question
-//If the question doesn't belong to userID or it doesn't exist it will result in an error
-func (s *SqliteStorage) DeleteOption(optionID, questionID, userID int) (models.Question, error) {
-	var question models.Question
-	if !s.HasQuestionAccess(userID, questionID) {
-		return question, fmt.Errorf("unauthorized")
-	}
-	_, err := s.DB.Exec(`DELETE FROM options WHERE id == (?) AND question_id == (?)`, optionID, questionID)
-	if err != nil {
-		return question, err
-	}
-	return s.Get(questionID, userID)
-}
-
-// Delete deletes an existing question
-//If the question doesn't belong to userID or it doesn't exist it will result in an error
-func (s *SqliteStorage) Delete(id, userID int) error {
-	_, err := s.DB.Exec(`DELETE FROM questions WHERE id == (?) AND user_id == (?)`, id, userID)
-	return err
-}
-
-// CreateUser creates a new user with username and password
-// If the user already exists it will return an error
-func (s *SqliteStorage) CreateUser(username, password string) (models.UserResponse, error) {
-	result, err := s.DB.Exec(`INSERT INTO users (username, password) values (?, ?)`, username, password)
-	if err != nil {
-		return models.UserResponse{}, err
-	}
-	id, err := result.LastInsertId()
-	return models.UserResponse{ID: int(id), Username: username}, err
-}
-
-// CreateToken creates a new JWT token for the user
-// If username and password are incorrect it will result in an error
-func (s *SqliteStorage) CreateToken(username, password string, secret []byte) (models.JWTTokenResponse, error) {
-	var jwtToken models.JWTTokenResponse
-	var user models.User
-	row := s.DB.QueryRow(`SELECT * FROM users WHERE username == (?) AND password == (?)`, username, password)
-	err := row.Scan(&user.ID, &user.Username, &user.Password)
-	if err != nil {
-		return jwtToken, err
-	}
-	token := jwt.New(jwt.GetSigningMethod("HS256"))
-	(*token).Claims.(jwt.MapClaims)["userID"] = user.ID
-	tokenString, err := token.SignedString(secret)
-	if err != nil {
-		return jwtToken, err
-	}
-	jwtToken.Token = tokenString
-
-	return jwtToken, nil
-}
-
-// UserIDExists checks if a given userID exists
-func (s *SqliteStorage) UserIDExists(userID int) bool {
-	row := s.DB.QueryRow(`SELECT * FROM users WHERE id == (?)`, userID)
-	var user models.User
-	err := row.Scan(&user.ID, &user.Username, &user.Password)
-	if err != nil {
-		return false
-	}
-	return true
-}
-
-// HasQuestionAccess verifies that a userID has access to a questionID
-// Returns true if the user has access and false if not
-func (s *SqliteStorage) HasQuestionAccess(userID, questionID int) bool {
-	row := s.DB.QueryRow(`SELECT questions.id FROM questions WHERE ID == (?) AND user_id == (?)`, questionID, userID)
-	var question models.Question
-	err := row.Scan(&question.ID)
-	if err != nil {
-		return false
-	}
-	return true
+	return 0, rows.Err()
 }
@@ -2,20 +2,95 @@ package storage
 
 import (
 	"github.com/makupi/backend-homework/models"
+	"time"
 )
 
 // Storage defines an interface with all needed functions for the REST API
 type Storage interface {
-	List(userID, lastID, limit int) []models.Question
+	List(userID int, page models.PageRequest) (models.QuestionPage, error)
 	Add(userID int, question models.Question) (models.Question, error)
 	Get(id, userID int) (models.Question, error)
 	Update(id, userID int, question models.Question) (models.Question, error)
 	Delete(id, userID int) error
+	ListTrash(userID int) ([]models.Question, error)
+	RestoreQuestion(id, userID int) (models.Question, error)
+	PurgeDeleted(before time.Time) (int, error)
 	CreateUser(username, password string) (models.UserResponse, error)
-	CreateToken(username, password string, secret []byte) (models.JWTTokenResponse, error)
+	CreateToken(username, password string, config TokenConfig) (models.JWTTokenResponse, error)
+	RefreshToken(refreshToken string, config TokenConfig) (models.JWTTokenResponse, error)
+	RevokeTokens(userID int, refreshToken, jti string, expiresAt time.Time) error
+	IsTokenRevoked(jti string) bool
 	UserIDExists(userID int) bool
 	HasQuestionAccess(userID, questionID int) bool
+	ListShares(questionID, userID int) ([]models.Share, error)
+	ShareQuestion(questionID, userID int, request models.ShareRequest) (models.Share, error)
+	RevokeShare(questionID, sharedUserID, userID int) error
 	AddOption(option models.Option, questionID, userID int) (models.Question, error)
 	UpdateOption(option models.Option, optionID, questionID, userID int) (models.Question, error)
 	DeleteOption(optionID, questionID, userID int) (models.Question, error)
+	ReorderOptions(optionIDs []int, questionID, userID int) (models.Question, error)
+	ListRevisions(questionID, userID int) ([]models.Revision, error)
+	GetRevision(questionID, number, userID int) (models.Revision, error)
+	RestoreRevision(questionID, number, userID int) (models.Question, error)
+	ListTags(userID int) ([]models.Tag, error)
+	GetTag(id, userID int) (models.Tag, error)
+	AddTag(userID int, tag models.Tag) (models.Tag, error)
+	UpdateTag(id, userID int, tag models.Tag) (models.Tag, error)
+	DeleteTag(id, userID int) error
+	ListTests(userID int) ([]models.Test, error)
+	GetTest(id, userID int) (models.Test, error)
+	AddTest(userID int, test models.Test) (models.Test, error)
+	UpdateTest(id, userID int, test models.Test) (models.Test, error)
+	DeleteTest(id, userID int) error
+	AddTestQuestions(id, userID int, questionIDs []int) (models.Test, error)
+	RemoveTestQuestion(id, questionID, userID int) (models.Test, error)
+	HasTestAccess(userID, testID int) bool
+	StartAttempt(userID int, request models.StartAttemptRequest) (models.Attempt, error)
+	ListAttempts(userID int) ([]models.Attempt, error)
+	GetAttempt(id, userID int) (models.Attempt, error)
+	CandidateAttempt(id int) (models.Attempt, error)
+	AnswerAttempt(id int, answer models.AnswerRequest) (models.Attempt, error)
+	SubmitAttempt(id int) (models.Attempt, error)
+	// Atomic runs fn as a single unit of work, either all changes made through tx are kept or none are
+	Atomic(fn func(tx Storage) error) error
+}
+
+// now returns the current time, tests replace it to travel in time
+var now = time.Now
+
+// timestamp returns the current time as stored by all Storage implementations
+// Postgres only keeps microseconds, truncating keeps timestamps in cursors exact everywhere
+func timestamp() time.Time {
+	return now().UTC().Truncate(time.Microsecond)
+}
+
+// appendMissing appends every ID of add that isn't part of ids yet, keeping the order of both
+func appendMissing(ids, add []int) []int {
+	result := append([]int{}, ids...)
+	for _, id := range add {
+		if _, found := removeID(result, id); !found {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// removeID returns ids without id and whether it was found
+func removeID(ids []int, id int) ([]int, bool) {
+	result := make([]int, 0, len(ids))
+	for _, current := range ids {
+		if current != id {
+			result = append(result, current)
+		}
+	}
+	return result, len(result) != len(ids)
+}
+
+// optionUpdatedAt returns when option is updated if it replaces current at position
+// The timestamp of current is kept if nothing changed, e.g. when only other options were edited
+func optionUpdatedAt(current, option models.Option, position int) time.Time {
+	if current.Body == option.Body && current.Correct == option.Correct && current.Position == position {
+		return current.UpdatedAt
+	}
+	return timestamp()
 }
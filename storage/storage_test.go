@@ -0,0 +1,1733 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/makupi/backend-homework/models"
+	"github.com/makupi/backend-homework/storage"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var passwords = storage.NewPasswordHasher(bcrypt.MinCost)
+
+var tokens = storage.TokenConfig{
+	Secret:     []byte("test-secret"),
+	Issuer:     "test",
+	AccessTTL:  time.Minute,
+	RefreshTTL: time.Hour,
+}
+
+// newStorageFunc returns a new, empty Storage for every call
+type newStorageFunc func(t *testing.T) storage.Storage
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage(passwords)
+	})
+}
+
+func TestSqliteStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) storage.Storage {
+		s, err := storage.NewSqliteStorage(filepath.Join(t.TempDir(), "db.sqlite3"), passwords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.DB.Close() })
+		return s
+	})
+}
+
+// TestPostgresStorage runs against the database in POSTGRES_TEST_URL, every table in it will be dropped
+func TestPostgresStorage(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+	testStorage(t, func(t *testing.T) storage.Storage {
+		migrator, err := storage.NewPostgresMigrator(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = migrator.Down(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrator.DB.Close()
+		s, err := storage.NewPostgresStorage(url, passwords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.DB.Close() })
+		return s
+	})
+}
+
+// testStorage is the behavioral contract every Storage implementation has to fulfil
+func testStorage(t *testing.T, newStorage newStorageFunc) {
+	tests := map[string]func(t *testing.T, s storage.Storage){
+		"Users":              testUsers,
+		"Tokens":             testTokens,
+		"Passwords":          testPasswords,
+		"ConcurrentRefresh":  testConcurrentRefresh,
+		"AddAndGet":          testAddAndGet,
+		"List":               testList,
+		"Pagination":         testPagination,
+		"Search":             testSearch,
+		"Tags":               testTags,
+		"Metadata":           testMetadata,
+		"QuestionTypes":      testQuestionTypes,
+		"Tests":              testTests,
+		"Attempts":           testAttempts,
+		"Revisions":          testRevisions,
+		"RevisionsByVersion": testRevisionsByVersion,
+		"Update":             testUpdate,
+		"Versions":           testVersions,
+		"Timestamps":         testTimestamps,
+		"Options":            testOptions,
+		"ReorderOptions":     testReorderOptions,
+		"Delete":             testDelete,
+		"Trash":              testTrash,
+		"AtomicRollsBack":    testAtomicRollsBack,
+		"QuestionOwnership":  testQuestionOwnership,
+		"Sharing":            testSharing,
+		"ValidationOnCreate": testValidationOnCreate,
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newStorage(t))
+		})
+	}
+}
+
+func newUser(t *testing.T, s storage.Storage, username string) int {
+	t.Helper()
+	user, err := s.CreateUser(username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func newQuestion(t *testing.T, s storage.Storage, userID int) models.Question {
+	t.Helper()
+	question, err := s.Add(userID, models.Question{
+		Body: "Where does the sun set?",
+		Options: []models.Option{
+			{Body: "East"},
+			{Body: "West", Correct: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return question
+}
+
+func optionBodies(question models.Question) []string {
+	bodies := make([]string, len(question.Options))
+	for i, option := range question.Options {
+		bodies[i] = option.Body
+	}
+	return bodies
+}
+
+func assertBodies(t *testing.T, question models.Question, want ...string) {
+	t.Helper()
+	got := optionBodies(question)
+	if len(got) != len(want) {
+		t.Fatalf("got options %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] || question.Options[i].Position != i {
+			t.Fatalf("got options %v, want %v", got, want)
+		}
+	}
+}
+
+func assertValidationError(t *testing.T, err error) {
+	t.Helper()
+	var validationErrors models.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("got %v, want validation error", err)
+	}
+}
+
+func assertIs(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("got %v, want %v", err, target)
+	}
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	if !s.UserIDExists(userID) || s.UserIDExists(userID+1) {
+		t.Fatal("UserIDExists doesn't match created users")
+	}
+	_, err := s.CreateUser("alice", "other")
+	assertIs(t, err, storage.ErrConflict)
+
+	_, err = s.CreateToken("alice", "wrong", tokens)
+	assertIs(t, err, storage.ErrInvalidCredentials)
+	_, err = s.CreateToken("bob", "password", tokens)
+	assertIs(t, err, storage.ErrInvalidCredentials)
+	token, err := s.CreateToken("alice", "password", tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token == "" || token.RefreshToken == "" {
+		t.Fatalf("got incomplete token response %+v", token)
+	}
+}
+
+func testTokens(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	token, err := s.CreateToken("alice", "password", tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := s.RefreshToken(token.RefreshToken, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RefreshToken(token.RefreshToken, tokens)
+	assertIs(t, err, storage.ErrInvalidToken)
+
+	if s.IsTokenRevoked("jti") {
+		t.Fatal("jti revoked before logout")
+	}
+	err = s.RevokeTokens(userID, refreshed.RefreshToken, "jti", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsTokenRevoked("jti") {
+		t.Fatal("jti not revoked after logout")
+	}
+	_, err = s.RefreshToken(refreshed.RefreshToken, tokens)
+	assertIs(t, err, storage.ErrInvalidToken)
+}
+
+// testPasswords logs in against legacy plaintext and outdated hashes, both are rehashed with the current cost
+func testPasswords(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	assertStoredCost := func() {
+		t.Helper()
+		stored, err := storage.StoredPassword(s, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := bcrypt.Cost([]byte(stored))
+		if err != nil || cost != passwords.Cost {
+			t.Fatalf("got stored password %q, want hash with cost %d", stored, passwords.Cost)
+		}
+	}
+	assertStoredCost()
+
+	outdated, err := storage.NewPasswordHasher(passwords.Cost + 1).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, stored := range map[string]string{"plaintext": "password", "outdated cost": outdated} {
+		t.Run(name, func(t *testing.T) {
+			err := storage.SetStoredPassword(s, userID, stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.CreateToken("alice", "wrong", tokens)
+			assertIs(t, err, storage.ErrInvalidCredentials)
+			got, err := storage.StoredPassword(s, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != stored {
+				t.Fatalf("wrong password replaced stored password with %q", got)
+			}
+
+			_, err = s.CreateToken("alice", "password", tokens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStoredCost()
+			_, err = s.CreateToken("alice", "password", tokens)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	_, err = s.CreateToken("alice", "wrong", tokens)
+	assertIs(t, err, storage.ErrInvalidCredentials)
+}
+
+// testConcurrentRefresh refreshes the same token in parallel, only one of them may get new tokens
+func testConcurrentRefresh(t *testing.T, s storage.Storage) {
+	newUser(t, s, "alice")
+	token, err := s.CreateToken("alice", "password", tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const refreshes = 8
+	errs := make(chan error, refreshes)
+	var wg sync.WaitGroup
+	for i := 0; i < refreshes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RefreshToken(token.RefreshToken, tokens)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, storage.ErrInvalidToken) {
+			t.Fatalf("got error %v, want %v", err, storage.ErrInvalidToken)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d of %d refreshes succeeded, want 1", succeeded, refreshes)
+	}
+}
+
+func testAddAndGet(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	got, err := s.Get(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Body != "Where does the sun set?" {
+		t.Fatalf("got body %q", got.Body)
+	}
+	assertBodies(t, got, "East", "West")
+	if got.Options[0].Correct || !got.Options[1].Correct {
+		t.Fatal("correct flags not stored")
+	}
+	_, err = s.Get(question.ID+1, userID)
+	assertIs(t, err, storage.ErrNotFound)
+}
+
+func testList(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	first := newQuestion(t, s, userID)
+	newQuestion(t, s, otherID)
+	second := newQuestion(t, s, userID)
+
+	page := list(t, s, userID, models.PageRequest{Sort: models.SortOldest})
+	if page.Total != 2 || len(page.Questions) != 2 || page.Questions[0].ID != first.ID || page.Questions[1].ID != second.ID {
+		t.Fatalf("got %+v, want questions %d and %d", page, first.ID, second.ID)
+	}
+	assertBodies(t, page.Questions[0], "East", "West")
+}
+
+func list(t *testing.T, s storage.Storage, userID int, request models.PageRequest) models.QuestionPage {
+	t.Helper()
+	if request.Limit == 0 {
+		request.Limit = models.DefaultPageSize
+	}
+	page, err := s.List(userID, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// decodeCursor returns the cursor of a page for the next request, nil if there is none
+func decodeCursor(t *testing.T, encoded, sort string) *models.Cursor {
+	t.Helper()
+	if encoded == "" {
+		return nil
+	}
+	cursor, err := models.DecodeCursor(encoded, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cursor
+}
+
+func pageIDs(page models.QuestionPage) []int {
+	ids := make([]int, len(page.Questions))
+	for i, question := range page.Questions {
+		ids[i] = question.ID
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, page models.QuestionPage, want ...int) {
+	t.Helper()
+	got := pageIDs(page)
+	if len(got) != len(want) {
+		t.Fatalf("got questions %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got questions %v, want %v", got, want)
+		}
+	}
+}
+
+func testPagination(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	var ids []int
+	for i := 0; i < 5; i++ {
+		ids = append(ids, newQuestion(t, s, userID).ID)
+	}
+
+	first := list(t, s, userID, models.PageRequest{Sort: models.SortNewest, Limit: 2})
+	assertIDs(t, first, ids[4], ids[3])
+	if first.Total != 5 || first.PrevCursor != "" || first.NextCursor == "" {
+		t.Fatalf("got first page %+v", first)
+	}
+	second := list(t, s, userID, models.PageRequest{
+		Sort: models.SortNewest, Limit: 2, Cursor: decodeCursor(t, first.NextCursor, models.SortNewest),
+	})
+	assertIDs(t, second, ids[2], ids[1])
+	last := list(t, s, userID, models.PageRequest{
+		Sort: models.SortNewest, Limit: 2, Cursor: decodeCursor(t, second.NextCursor, models.SortNewest),
+	})
+	assertIDs(t, last, ids[0])
+	if last.NextCursor != "" || last.PrevCursor == "" {
+		t.Fatalf("got last page %+v", last)
+	}
+	back := list(t, s, userID, models.PageRequest{
+		Sort: models.SortNewest, Limit: 2, Cursor: decodeCursor(t, second.PrevCursor, models.SortNewest),
+	})
+	assertIDs(t, back, ids[4], ids[3])
+	if back.PrevCursor != "" {
+		t.Fatalf("got prev cursor on the first page %+v", back)
+	}
+
+	oldest := list(t, s, userID, models.PageRequest{Sort: models.SortOldest, Limit: 3})
+	assertIDs(t, oldest, ids[0], ids[1], ids[2])
+
+	time.Sleep(time.Millisecond)
+	_, err := s.AddOption(models.Option{Body: "North"}, ids[1], userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := list(t, s, userID, models.PageRequest{Sort: models.SortUpdated, Limit: 1})
+	assertIDs(t, updated, ids[1])
+	rest := list(t, s, userID, models.PageRequest{
+		Sort: models.SortUpdated, Limit: 10, Cursor: decodeCursor(t, updated.NextCursor, models.SortUpdated),
+	})
+	assertIDs(t, rest, ids[4], ids[3], ids[2], ids[0])
+}
+
+func testUpdate(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	east, west := question.Options[0], question.Options[1]
+
+	updated, err := s.Update(question.ID, userID, models.Question{
+		Body: "Where does the sun rise?",
+		Options: []models.Option{
+			{Body: "North"},
+			{ID: west.ID, Body: "West"},
+			{ID: east.ID, Body: "East", Correct: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Body != "Where does the sun rise?" {
+		t.Fatalf("got body %q", updated.Body)
+	}
+	assertBodies(t, updated, "North", "West", "East")
+	if updated.Options[1].ID != west.ID || !updated.Options[2].Correct {
+		t.Fatalf("existing options not updated: %+v", updated.Options)
+	}
+
+	updated, err = s.Update(question.ID, userID, models.Question{
+		Body:    "Where does the sun rise?",
+		Options: []models.Option{{Body: "Up"}, {ID: east.ID, Body: "East", Correct: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, updated, "Up", "East")
+
+	_, err = s.Update(question.ID, userID, models.Question{
+		Body:    "Where does the sun rise?",
+		Options: []models.Option{{ID: west.ID, Body: "West"}, {Body: "East", Correct: true}},
+	})
+	assertValidationError(t, err)
+}
+
+func testVersions(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	if question.Version != 1 {
+		t.Fatalf("got version %d, want 1", question.Version)
+	}
+
+	changed := question
+	changed.Body = "Where does the sun rise?"
+	updated, err := s.Update(question.ID, userID, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("got version %d, want 2", updated.Version)
+	}
+	_, err = s.Update(question.ID, userID, changed)
+	if !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("got %v, want ErrVersionMismatch", err)
+	}
+
+	updated, err = s.AddOption(models.Option{Body: "North"}, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 3 {
+		t.Fatalf("got version %d, want 3", updated.Version)
+	}
+	changed.Version = 0
+	updated, err = s.Update(question.ID, userID, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 4 {
+		t.Fatalf("got version %d, want 4", updated.Version)
+	}
+	stored, err := s.Get(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 4 {
+		t.Fatalf("got stored version %d, want 4", stored.Version)
+	}
+}
+
+func testTimestamps(t *testing.T, s storage.Storage) {
+	created := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := created
+	defer storage.SetNow(func() time.Time { return clock })()
+	user, err := s.CreateUser("alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.CreatedAt.Equal(created) || !user.UpdatedAt.Equal(created) {
+		t.Fatalf("got user %+v", user)
+	}
+	question := newQuestion(t, s, user.ID)
+	if !question.CreatedAt.Equal(created) || !question.UpdatedAt.Equal(created) || question.CreatedBy != user.ID ||
+		question.UpdatedBy != user.ID || !question.Options[0].CreatedAt.Equal(created) {
+		t.Fatalf("got question %+v", question)
+	}
+
+	clock = created.Add(time.Hour)
+	question.Options[1].Body = "Far west"
+	question, err = s.Update(question.ID, user.ID, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !question.CreatedAt.Equal(created) || !question.UpdatedAt.Equal(clock) {
+		t.Fatalf("got question created at %s and updated at %s", question.CreatedAt, question.UpdatedAt)
+	}
+	east, west := question.Options[0], question.Options[1]
+	if !east.UpdatedAt.Equal(created) || !west.CreatedAt.Equal(created) || !west.UpdatedAt.Equal(clock) {
+		t.Fatalf("got options %+v", question.Options)
+	}
+
+	clock = created.Add(2 * time.Hour)
+	question, err = s.AddOption(models.Option{Body: "North"}, question.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !question.UpdatedAt.Equal(clock) || !question.Options[2].CreatedAt.Equal(clock) || !question.Options[0].UpdatedAt.Equal(created) {
+		t.Fatalf("got question %+v", question)
+	}
+	stored, err := s.Get(question.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.UpdatedAt.Equal(clock) || !stored.Options[1].UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Fatalf("got stored question %+v", stored)
+	}
+
+	other := newQuestion(t, s, user.ID)
+	assertIDs(t, list(t, s, user.ID, models.PageRequest{UpdatedSince: created.Add(time.Hour)}), other.ID, question.ID)
+	assertIDs(t, list(t, s, user.ID, models.PageRequest{UpdatedSince: clock.Add(time.Second)}))
+}
+
+func testOptions(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	east, west := question.Options[0], question.Options[1]
+
+	question, err := s.AddOption(models.Option{Body: "North"}, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, question, "East", "West", "North")
+
+	question, err = s.UpdateOption(models.Option{Body: "Eastwards", Correct: true}, east.ID, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, question, "Eastwards", "West", "North")
+	_, err = s.UpdateOption(models.Option{Body: "Missing"}, 0, question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.UpdateOption(models.Option{Body: ""}, west.ID, question.ID, userID)
+	assertValidationError(t, err)
+
+	question, err = s.DeleteOption(west.ID, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, question, "Eastwards", "North")
+	_, err = s.DeleteOption(east.ID, question.ID, userID)
+	assertValidationError(t, err)
+	_, err = s.DeleteOption(west.ID, question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+}
+
+func testReorderOptions(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	east, west := question.Options[0], question.Options[1]
+
+	question, err := s.ReorderOptions([]int{west.ID, east.ID}, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, question, "West", "East")
+	_, err = s.ReorderOptions([]int{west.ID, west.ID}, question.ID, userID)
+	assertValidationError(t, err)
+	_, err = s.ReorderOptions([]int{west.ID}, question.ID, userID)
+	assertValidationError(t, err)
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	err := s.Delete(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get(question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	assertIs(t, s.Delete(question.ID, userID), storage.ErrNotFound)
+	assertIs(t, s.Delete(-1, userID), storage.ErrNotFound)
+	other := newQuestion(t, s, newUser(t, s, "bob"))
+	assertIs(t, s.Delete(other.ID, userID), storage.ErrForbidden)
+}
+
+func testTrash(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	question, err := s.Add(userID, models.Question{
+		Body:    "Where does the sun set?",
+		Options: []models.Option{{Body: "East"}, {Body: "West", Correct: true}},
+		Tags:    []string{"geography"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := newQuestion(t, s, userID)
+	test, err := s.AddTest(userID, models.Test{Title: "Geography", QuestionIDs: []int{question.ID, kept.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Delete(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, list(t, s, userID, models.PageRequest{}), kept.ID)
+	trash, err := s.ListTrash(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != question.ID || trash[0].DeletedAt == nil || len(trash[0].Options) != 2 {
+		t.Fatalf("got trash %+v", trash)
+	}
+	if s.HasQuestionAccess(userID, question.ID) {
+		t.Fatal("deleted question is still accessible")
+	}
+	tags, err := s.ListTags(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].QuestionCount != 0 {
+		t.Fatalf("got tags %+v", tags)
+	}
+	test, err = s.GetTest(test.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(test.QuestionIDs, kept.ID) {
+		t.Fatalf("got questions %v", test.QuestionIDs)
+	}
+	_, err = s.UpdateOption(models.Option{Body: "North"}, question.Options[0].ID, question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.AddTest(userID, models.Test{Title: "Deleted", QuestionIDs: []int{question.ID}})
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.RestoreQuestion(question.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.RestoreQuestion(kept.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+
+	restored, err := s.RestoreQuestion(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || len(restored.Options) != 2 || len(restored.Tags) != 1 {
+		t.Fatalf("got %+v", restored)
+	}
+	trash, err = s.ListTrash(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 0 {
+		t.Fatalf("got trash %+v", trash)
+	}
+
+	err = s.Delete(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purged, err := s.PurgeDeleted(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("got %d purged, error %v", purged, err)
+	}
+	purged, err = s.PurgeDeleted(time.Now().Add(time.Second))
+	if err != nil || purged != 1 {
+		t.Fatalf("got %d purged, error %v", purged, err)
+	}
+	_, err = s.RestoreQuestion(question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.Get(kept.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAtomicRollsBack(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	failure := errors.New("failure")
+	err := s.Atomic(func(tx storage.Storage) error {
+		newQuestion(t, tx, userID)
+		return failure
+	})
+	assertIs(t, err, failure)
+	if page := list(t, s, userID, models.PageRequest{Sort: models.SortNewest}); page.Total != 0 {
+		t.Fatalf("got %d questions after rollback", page.Total)
+	}
+
+	err = s.Atomic(func(tx storage.Storage) error {
+		newQuestion(t, tx, userID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := list(t, s, userID, models.PageRequest{Sort: models.SortNewest}); page.Total != 1 {
+		t.Fatalf("got %d questions after commit", page.Total)
+	}
+
+	// every kind of change is reverted, not only new entities
+	newUser(t, s, "bob")
+	question := newQuestion(t, s, userID)
+	question.Tags = []string{"space"}
+	question, err = s.Update(question.ID, userID, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := s.AddTest(userID, models.Test{Title: "Geography", QuestionIDs: []int{question.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := s.ListTags(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Atomic(func(tx storage.Storage) error {
+		changed := question
+		changed.Body = "Where is it warm?"
+		changed.Options = []models.Option{{Body: "North"}, {Body: "South", Correct: true}}
+		if _, err := tx.Update(question.ID, userID, changed); err != nil {
+			return err
+		}
+		if _, err := tx.ShareQuestion(question.ID, userID, models.ShareRequest{Username: "bob", Role: models.RoleEditor}); err != nil {
+			return err
+		}
+		if _, err := tx.UpdateTag(tags[0].ID, userID, models.Tag{Name: "astronomy"}); err != nil {
+			return err
+		}
+		if err := tx.Delete(question.ID, userID); err != nil {
+			return err
+		}
+		return failure
+	})
+	assertIs(t, err, failure)
+	got, err := s.Get(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, question) {
+		t.Fatalf("got %+v after rollback, want %+v", got, question)
+	}
+	revisions, err := s.ListRevisions(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := s.ListShares(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err = s.GetTest(test.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != question.Version || len(shares) != 0 || len(test.QuestionIDs) != 1 {
+		t.Fatalf("got %d revisions, shares %+v and test %+v after rollback", len(revisions), shares, test)
+	}
+}
+
+func testQuestionOwnership(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	question := newQuestion(t, s, userID)
+
+	if !s.HasQuestionAccess(userID, question.ID) || s.HasQuestionAccess(otherID, question.ID) {
+		t.Fatal("HasQuestionAccess doesn't match the owner")
+	}
+	_, err := s.Get(question.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.Update(question.ID, otherID, question)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.AddOption(models.Option{Body: "North"}, question.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+}
+
+func testSharing(t *testing.T, s storage.Storage) {
+	ownerID := newUser(t, s, "alice")
+	collaboratorID := newUser(t, s, "bob")
+	coOwnerID := newUser(t, s, "carol")
+	question := newQuestion(t, s, ownerID)
+	share := func(username, role string) {
+		t.Helper()
+		shared, err := s.ShareQuestion(question.ID, ownerID, models.ShareRequest{Username: username, Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shared.Username != username || shared.Role != role {
+			t.Fatalf("got share %+v", shared)
+		}
+	}
+
+	share("bob", models.RoleViewer)
+	if !s.HasQuestionAccess(collaboratorID, question.ID) {
+		t.Fatal("viewer has no access")
+	}
+	assertIDs(t, list(t, s, collaboratorID, models.PageRequest{}), question.ID)
+	_, err := s.Get(question.ID, collaboratorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Update(question.ID, collaboratorID, question)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.AddOption(models.Option{Body: "North"}, question.ID, collaboratorID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.ShareQuestion(question.ID, collaboratorID, models.ShareRequest{Username: "carol", Role: models.RoleViewer})
+	assertIs(t, err, storage.ErrForbidden)
+	test, err := s.AddTest(collaboratorID, models.Test{Title: "Shared", QuestionIDs: []int{question.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	share("bob", models.RoleEditor)
+	question.Tags = []string{"astronomy"}
+	updated, err := s.Update(question.ID, collaboratorID, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.CreatedBy != ownerID || updated.UpdatedBy != collaboratorID || len(updated.Tags) != 1 {
+		t.Fatalf("got question %+v", updated)
+	}
+	tags, err := s.ListTags(ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "astronomy" {
+		t.Fatalf("got tags of the owner %+v", tags)
+	}
+	err = s.Delete(question.ID, collaboratorID)
+	assertIs(t, err, storage.ErrForbidden)
+
+	share("carol", models.RoleOwner)
+	shares, err := s.ListShares(question.ID, collaboratorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 || shares[0].UserID != collaboratorID || shares[1].UserID != coOwnerID {
+		t.Fatalf("got shares %+v", shares)
+	}
+	err = s.Delete(question.ID, coOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash, err := s.ListTrash(coOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 {
+		t.Fatalf("got trash %+v", trash)
+	}
+	_, err = s.RestoreQuestion(question.ID, coOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.ShareQuestion(question.ID, coOwnerID, models.ShareRequest{Username: "alice", Role: models.RoleViewer})
+	assertIs(t, err, storage.ErrConflict)
+	_, err = s.ShareQuestion(question.ID, ownerID, models.ShareRequest{Username: "dave", Role: models.RoleViewer})
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.ShareQuestion(question.ID, ownerID, models.ShareRequest{Username: "bob", Role: "admin"})
+	assertValidationError(t, err)
+
+	err = s.RevokeShare(question.ID, ownerID, coOwnerID)
+	assertIs(t, err, storage.ErrNotFound)
+	err = s.RevokeShare(question.ID, collaboratorID, coOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get(question.ID, collaboratorID)
+	assertIs(t, err, storage.ErrForbidden)
+	test, err = s.GetTest(test.ID, collaboratorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(test.QuestionIDs) != 0 {
+		t.Fatalf("revoked question is still part of test %+v", test)
+	}
+	err = s.RevokeShare(question.ID, coOwnerID, coOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err = s.ListShares(question.ID, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 0 {
+		t.Fatalf("got shares %+v", shares)
+	}
+}
+
+func testValidationOnCreate(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	_, err := s.Add(userID, models.Question{Body: "No options"})
+	assertValidationError(t, err)
+	_, err = s.Add(userID, models.Question{
+		Body:    "Nothing correct",
+		Options: []models.Option{{Body: "A"}, {Body: "B"}},
+	})
+	assertValidationError(t, err)
+}
+
+func search(t *testing.T, s storage.Storage, userID int, q, sort string) models.QuestionPage {
+	t.Helper()
+	query, err := models.ParseSearchQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list(t, s, userID, models.PageRequest{Sort: sort, Query: &query})
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	add := func(userID int, body string, options ...string) models.Question {
+		t.Helper()
+		question := models.Question{Body: body}
+		for i, option := range options {
+			question.Options = append(question.Options, models.Option{Body: option, Correct: i == 0})
+		}
+		added, err := s.Add(userID, question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+	sun := add(userID, "Where does the sun set?", "West", "East")
+	planets := add(userID, "Which planet is closest to the sun? The sun is a star.", "Mercury", "Venus")
+	languages := add(userID, "Which language has goroutines?", "Go", "Sunscript")
+	add(otherID, "Where does the sun rise?", "East", "West")
+
+	// planets mentions the sun twice
+	page := search(t, s, userID, "sun", models.SortRelevance)
+	assertIDs(t, page, planets.ID, sun.ID)
+	if page.Total != 2 || !strings.Contains(page.Questions[1].Snippet, "<mark>sun</mark>") {
+		t.Fatalf("got %+v", page)
+	}
+	assertIDs(t, search(t, s, userID, "sun*", models.SortOldest), sun.ID, planets.ID, languages.ID)
+	assertIDs(t, search(t, s, userID, `"sun set"`, models.SortRelevance), sun.ID)
+	assertIDs(t, search(t, s, userID, `"set sun"`, models.SortRelevance))
+	assertIDs(t, search(t, s, userID, "mercury", models.SortRelevance), planets.ID)
+	assertIDs(t, search(t, s, userID, "WHICH go", models.SortRelevance), languages.ID)
+
+	_, err := s.UpdateOption(models.Option{Body: "Neptune", Correct: true}, planets.Options[0].ID, planets.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, search(t, s, userID, "mercury", models.SortRelevance))
+	assertIDs(t, search(t, s, userID, "neptune", models.SortRelevance), planets.ID)
+	_, err = s.Update(sun.ID, userID, models.Question{
+		Body:    "Where does the moon set?",
+		Options: []models.Option{{Body: "West", Correct: true}, {Body: "East"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, search(t, s, userID, "moon", models.SortRelevance), sun.ID)
+	err = s.Delete(sun.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, search(t, s, userID, "moon", models.SortRelevance))
+
+	first := list(t, s, userID, models.PageRequest{Sort: models.SortRelevance, Limit: 1, Query: &models.SearchQuery{
+		Terms: []models.SearchTerm{{Words: []string{"which"}}},
+	}})
+	if first.Total != 2 || first.NextCursor == "" {
+		t.Fatalf("got %+v", first)
+	}
+	second := list(t, s, userID, models.PageRequest{
+		Sort: models.SortRelevance, Limit: 1, Query: &models.SearchQuery{Terms: []models.SearchTerm{{Words: []string{"which"}}}},
+		Cursor: decodeCursor(t, first.NextCursor, models.SortRelevance),
+	})
+	if len(second.Questions) != 1 || second.Questions[0].ID == first.Questions[0].ID || second.NextCursor != "" {
+		t.Fatalf("got %+v after %+v", second, first)
+	}
+
+	// snippets are HTML-escaped, only the highlights are markup
+	add(otherID, `<script>alert("bold")</script> is <b>bold</b>`, "Yes", "No")
+	page = search(t, s, otherID, "bold", models.SortRelevance)
+	if len(page.Questions) != 1 || strings.Contains(page.Questions[0].Snippet, "<script>") ||
+		strings.Contains(page.Questions[0].Snippet, "<b>") || !strings.Contains(page.Questions[0].Snippet, "<mark>bold</mark>") {
+		t.Fatalf("got %+v", page)
+	}
+}
+
+func testTags(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	add := func(userID int, tags ...string) models.Question {
+		t.Helper()
+		question, err := s.Add(userID, models.Question{
+			Body:    "Which language has goroutines?",
+			Options: []models.Option{{Body: "Go", Correct: true}, {Body: "SQL"}},
+			Tags:    tags,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return question
+	}
+	byTags := func(mode string, tags ...string) models.QuestionPage {
+		t.Helper()
+		return list(t, s, userID, models.PageRequest{Sort: models.SortOldest, Tags: tags, TagMode: mode})
+	}
+	goroutines := add(userID, " Go", "concurrency", "go")
+	if len(goroutines.Tags) != 2 || goroutines.Tags[0] != "concurrency" || goroutines.Tags[1] != "go" {
+		t.Fatalf("got tags %v", goroutines.Tags)
+	}
+	joins := add(userID, "sql")
+	untagged := add(userID)
+	if untagged.Tags == nil || len(untagged.Tags) != 0 {
+		t.Fatalf("got tags %v, want empty", untagged.Tags)
+	}
+	add(otherID, "go")
+
+	assertIDs(t, byTags(models.TagModeAll, "go"), goroutines.ID)
+	assertIDs(t, byTags(models.TagModeAll, "concurrency", "go"), goroutines.ID)
+	assertIDs(t, byTags(models.TagModeAll, "go", "sql"))
+	assertIDs(t, byTags(models.TagModeAny, "go", "sql"), goroutines.ID, joins.ID)
+	assertIDs(t, byTags(models.TagModeAny, "unknown"))
+	if page := byTags(models.TagModeAny, "go", "sql"); page.Total != 2 || page.Questions[0].Tags[1] != "go" {
+		t.Fatalf("got %+v", page)
+	}
+
+	tags, err := s.ListTags(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags[0].Name != "concurrency" || tags[1].Name != "go" || tags[1].QuestionCount != 1 || tags[2].Name != "sql" {
+		t.Fatalf("got %+v", tags)
+	}
+	goTag := tags[1]
+
+	_, err = s.GetTag(goTag.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.GetTag(-1, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.AddTag(userID, models.Tag{Name: "GO"})
+	assertIs(t, err, storage.ErrConflict)
+	_, err = s.AddTag(userID, models.Tag{Name: "no spaces"})
+	assertValidationError(t, err)
+	_, err = s.Add(userID, models.Question{
+		Body:    "Which language has goroutines?",
+		Options: []models.Option{{Body: "Go", Correct: true}, {Body: "SQL"}},
+		Tags:    []string{"no/slashes"},
+	})
+	assertValidationError(t, err)
+
+	empty, err := s.AddTag(userID, models.Tag{Name: "C++"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Name != "c++" || empty.QuestionCount != 0 {
+		t.Fatalf("got %+v", empty)
+	}
+	_, err = s.UpdateTag(goTag.ID, userID, models.Tag{Name: "c++"})
+	assertIs(t, err, storage.ErrConflict)
+	renamed, err := s.UpdateTag(goTag.ID, userID, models.Tag{Name: "golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "golang" || renamed.QuestionCount != 1 {
+		t.Fatalf("got %+v", renamed)
+	}
+	question, err := s.Get(goroutines.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(question.Tags) != 2 || question.Tags[1] != "golang" {
+		t.Fatalf("got tags %v", question.Tags)
+	}
+	assertIDs(t, byTags(models.TagModeAll, "golang"), goroutines.ID)
+
+	err = s.DeleteTag(goTag.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	err = s.DeleteTag(goTag.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	question, err = s.Get(goroutines.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(question.Tags) != 1 || question.Tags[0] != "concurrency" {
+		t.Fatalf("got tags %v", question.Tags)
+	}
+	assertIDs(t, byTags(models.TagModeAll, "golang"))
+
+	// updating a question replaces its tags
+	_, err = s.Update(joins.ID, userID, models.Question{
+		Body:    "Which language has joins?",
+		Options: []models.Option{{Body: "SQL", Correct: true}, {Body: "Go"}},
+		Tags:    []string{"databases"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, byTags(models.TagModeAll, "sql"))
+	assertIDs(t, byTags(models.TagModeAll, "databases"), joins.ID)
+}
+
+func testMetadata(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	defaults := newQuestion(t, s, userID)
+	if defaults.Difficulty != models.DefaultDifficulty || defaults.Points != models.DefaultPoints || defaults.TimeLimit != 0 {
+		t.Fatalf("got %+v", defaults)
+	}
+	hard, err := s.Add(userID, models.Question{
+		Body:        "Which language has goroutines?",
+		Options:     []models.Option{{Body: "Go", Correct: true}, {Body: "SQL"}},
+		Difficulty:  models.DifficultyHard,
+		TimeLimit:   90,
+		Points:      5,
+		Explanation: "Goroutines are lightweight threads managed by the Go runtime.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(hard.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Difficulty != models.DifficultyHard || got.TimeLimit != 90 || got.Points != 5 || got.Explanation != hard.Explanation {
+		t.Fatalf("got %+v", got)
+	}
+
+	byMetadata := func(request models.PageRequest) models.QuestionPage {
+		t.Helper()
+		request.Sort = models.SortOldest
+		return list(t, s, userID, request)
+	}
+	assertIDs(t, byMetadata(models.PageRequest{Difficulties: []string{models.DifficultyHard}}), hard.ID)
+	assertIDs(t, byMetadata(models.PageRequest{Difficulties: []string{models.DifficultyEasy, models.DifficultyMedium}}), defaults.ID)
+	assertIDs(t, byMetadata(models.PageRequest{MinPoints: 2}), hard.ID)
+	assertIDs(t, byMetadata(models.PageRequest{MaxPoints: 4}), defaults.ID)
+	assertIDs(t, byMetadata(models.PageRequest{MaxTimeLimit: 120}), hard.ID)
+	assertIDs(t, byMetadata(models.PageRequest{MaxTimeLimit: 60}))
+
+	got.Difficulty = "impossible"
+	got.Points = -1
+	got.TimeLimit = models.MaxTimeLimit + 1
+	_, err = s.Update(hard.ID, userID, got)
+	var validationErrors models.ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 3 {
+		t.Fatalf("got %v, want 3 validation errors", err)
+	}
+	got.Difficulty, got.Points, got.TimeLimit = models.DifficultyEasy, 0, 0
+	updated, err := s.Update(hard.ID, userID, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Difficulty != models.DifficultyEasy || updated.Points != models.DefaultPoints || updated.Explanation != hard.Explanation {
+		t.Fatalf("got %+v", updated)
+	}
+}
+
+func testQuestionTypes(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	if question := newQuestion(t, s, userID); question.Type != models.DefaultQuestionType {
+		t.Fatalf("got type %q", question.Type)
+	}
+	freeText, err := s.Add(userID, models.Question{
+		Body: "Which keyword starts a goroutine?",
+		Type: models.QuestionTypeFreeText,
+		AcceptedAnswers: []models.AcceptedAnswer{
+			{Answer: "go"},
+			{Answer: "go( func)?", Regex: true, CaseSensitive: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(freeText.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != models.QuestionTypeFreeText || len(got.Options) != 0 || got.Options == nil || len(got.AcceptedAnswers) != 2 ||
+		!got.AcceptedAnswers[1].Regex || !got.AcceptedAnswers[1].CaseSensitive || got.NumericAnswer != nil {
+		t.Fatalf("got %+v", got)
+	}
+
+	numeric, err := s.Add(userID, models.Question{
+		Body:          "What is pi to two decimals?",
+		Type:          models.QuestionTypeNumeric,
+		NumericAnswer: &models.NumericAnswer{Value: 3.14, Tolerance: 0.005},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numeric.NumericAnswer == nil || numeric.NumericAnswer.Value != 3.14 || numeric.AcceptedAnswers != nil {
+		t.Fatalf("got %+v", numeric)
+	}
+
+	// switching the type replaces the answer key
+	numeric.Type = models.QuestionTypeTrueFalse
+	numeric.NumericAnswer = nil
+	numeric.Options = []models.Option{{Body: "True"}, {Body: "False", Correct: true}}
+	numeric, err = s.Update(numeric.ID, userID, numeric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numeric.Type != models.QuestionTypeTrueFalse || numeric.NumericAnswer != nil || len(numeric.Options) != 2 {
+		t.Fatalf("got %+v", numeric)
+	}
+	freeText.AcceptedAnswers = freeText.AcceptedAnswers[:1]
+	freeText, err = s.Update(freeText.ID, userID, freeText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(freeText.AcceptedAnswers) != 1 {
+		t.Fatalf("got %+v", freeText)
+	}
+	page := list(t, s, userID, models.PageRequest{Sort: models.SortOldest})
+	if len(page.Questions) != 3 || len(page.Questions[1].AcceptedAnswers) != 1 || page.Questions[2].Type != models.QuestionTypeTrueFalse {
+		t.Fatalf("got %+v", page)
+	}
+
+	invalid := []models.Question{
+		{Body: "Unknown", Type: "essay"},
+		{Body: "Two correct", Type: models.QuestionTypeSingleChoice, Options: []models.Option{
+			{Body: "A", Correct: true}, {Body: "B", Correct: true},
+		}},
+		{Body: "Three options", Type: models.QuestionTypeTrueFalse, Options: []models.Option{
+			{Body: "True", Correct: true}, {Body: "False"}, {Body: "Maybe"},
+		}},
+		{Body: "No answers", Type: models.QuestionTypeFreeText},
+		{Body: "Bad regex", Type: models.QuestionTypeFreeText, AcceptedAnswers: []models.AcceptedAnswer{{Answer: "(", Regex: true}}},
+		{Body: "Options", Type: models.QuestionTypeFreeText, AcceptedAnswers: []models.AcceptedAnswer{{Answer: "a"}}, Options: []models.Option{
+			{Body: "A", Correct: true}, {Body: "B"},
+		}},
+		{Body: "No value", Type: models.QuestionTypeNumeric},
+		{Body: "Negative", Type: models.QuestionTypeNumeric, NumericAnswer: &models.NumericAnswer{Value: 1, Tolerance: -1}},
+		{Body: "Numeric on choice", NumericAnswer: &models.NumericAnswer{Value: 1}, Options: []models.Option{
+			{Body: "A", Correct: true}, {Body: "B"},
+		}},
+	}
+	for _, question := range invalid {
+		_, err := s.Add(userID, question)
+		assertValidationError(t, err)
+	}
+}
+
+func testTests(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	first := newQuestion(t, s, userID)
+	second := newQuestion(t, s, userID)
+	third := newQuestion(t, s, userID)
+	foreign := newQuestion(t, s, otherID)
+
+	test, err := s.AddTest(userID, models.Test{
+		Title:         "Backend developer",
+		TimeLimit:     1800,
+		PassThreshold: 60,
+		QuestionIDs:   []int{second.ID, first.ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.ID == 0 || test.Title != "Backend developer" || test.PassThreshold != 60 || !equalIDs(test.QuestionIDs, second.ID, first.ID) {
+		t.Fatalf("got %+v", test)
+	}
+	if !s.HasTestAccess(userID, test.ID) || s.HasTestAccess(otherID, test.ID) {
+		t.Fatal("HasTestAccess doesn't match the owner")
+	}
+	_, err = s.GetTest(test.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.AddTest(userID, models.Test{Title: "Foreign", QuestionIDs: []int{foreign.ID}})
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.AddTest(userID, models.Test{Title: "Missing", QuestionIDs: []int{-1}})
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.AddTest(userID, models.Test{Title: " ", PassThreshold: 101, QuestionIDs: []int{first.ID, first.ID}})
+	assertValidationError(t, err)
+
+	test, err = s.AddTestQuestions(test.ID, userID, []int{first.ID, third.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(test.QuestionIDs, second.ID, first.ID, third.ID) {
+		t.Fatalf("got questions %v", test.QuestionIDs)
+	}
+	_, err = s.AddTestQuestions(test.ID, userID, []int{foreign.ID})
+	assertIs(t, err, storage.ErrForbidden)
+	test, err = s.RemoveTestQuestion(test.ID, second.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(test.QuestionIDs, first.ID, third.ID) {
+		t.Fatalf("got questions %v", test.QuestionIDs)
+	}
+	_, err = s.RemoveTestQuestion(test.ID, second.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+
+	test.Title = "Senior backend developer"
+	test.QuestionIDs = []int{third.ID, first.ID}
+	test, err = s.UpdateTest(test.ID, userID, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.Title != "Senior backend developer" || !equalIDs(test.QuestionIDs, third.ID, first.ID) {
+		t.Fatalf("got %+v", test)
+	}
+
+	// deleting a question removes it from its tests
+	err = s.Delete(third.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := s.AddTest(userID, models.Test{Title: "Empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := s.ListTests(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) != 2 || !equalIDs(tests[0].QuestionIDs, first.ID) || tests[1].ID != empty.ID || tests[1].QuestionIDs == nil {
+		t.Fatalf("got %+v", tests)
+	}
+	err = s.DeleteTest(empty.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DeleteTest(test.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	err = s.DeleteTest(test.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetTest(test.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	if _, err := s.Get(first.ID, userID); err != nil {
+		t.Fatalf("deleting a test deleted its question: %v", err)
+	}
+}
+
+func equalIDs(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testAttempts(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	first := newQuestion(t, s, userID)
+	second, err := s.Add(userID, models.Question{
+		Body:            "What is the capital of France?",
+		Type:            models.QuestionTypeFreeText,
+		AcceptedAnswers: []models.AcceptedAnswer{{Answer: "Paris"}},
+		Points:          3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third := newQuestion(t, s, userID)
+	foreign := newQuestion(t, s, otherID)
+	test, err := s.AddTest(userID, models.Test{
+		Title:         "Geography",
+		TimeLimit:     600,
+		PassThreshold: 50,
+		QuestionIDs:   []int{first.ID, second.ID, third.ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempt, err := s.StartAttempt(userID, models.StartAttemptRequest{TestID: test.ID, Candidate: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Status != models.AttemptInProgress || attempt.MaxScore != 5 || !equalIDs(attempt.QuestionIDs, first.ID, second.ID, third.ID) {
+		t.Fatalf("got %+v", attempt)
+	}
+	if attempt.ExpiresAt == nil || !attempt.ExpiresAt.Equal(attempt.StartedAt.Add(600*time.Second)) {
+		t.Fatalf("got expires_at %v for started_at %v", attempt.ExpiresAt, attempt.StartedAt)
+	}
+	_, err = s.AnswerAttempt(attempt.ID, models.AnswerRequest{QuestionID: second.ID})
+	assertValidationError(t, err)
+	west := models.CheckRequest{OptionIDs: []int{first.Options[1].ID}}
+	_, err = s.AnswerAttempt(attempt.ID, models.AnswerRequest{QuestionID: first.ID, CheckRequest: west})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris := models.CheckRequest{Answer: " paris "}
+	attempt, err = s.AnswerAttempt(attempt.ID, models.AnswerRequest{QuestionID: second.ID, CheckRequest: paris})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempt.Answers) != 2 || !attempt.Answers[1].Correct || attempt.Answers[1].Points != 3 || attempt.Passed != nil {
+		t.Fatalf("got %+v", attempt)
+	}
+	attempt, err = s.SubmitAttempt(attempt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Status != models.AttemptSubmitted || attempt.Score != 4 || attempt.Percentage != 80 || attempt.Passed == nil || !*attempt.Passed {
+		t.Fatalf("got %+v", attempt)
+	}
+	_, err = s.AnswerAttempt(attempt.ID, models.AnswerRequest{QuestionID: third.ID, CheckRequest: west})
+	assertIs(t, err, storage.ErrAttemptClosed)
+	_, err = s.SubmitAttempt(attempt.ID)
+	assertIs(t, err, storage.ErrAttemptClosed)
+	stored, err := s.GetAttempt(attempt.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Score != 4 || len(stored.Answers) != 2 || stored.Answers[0].QuestionID != first.ID {
+		t.Fatalf("got %+v", stored)
+	}
+	_, err = s.GetAttempt(attempt.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.GetAttempt(-1, userID)
+	assertIs(t, err, storage.ErrNotFound)
+
+	timed, err := s.StartAttempt(userID, models.StartAttemptRequest{QuestionIDs: []int{first.ID, third.ID}, TimeLimit: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := storage.SetNow(func() time.Time { return time.Now().Add(2 * time.Minute) })
+	_, err = s.AnswerAttempt(timed.ID, models.AnswerRequest{QuestionID: first.ID, CheckRequest: west})
+	assertIs(t, err, storage.ErrAttemptClosed)
+	restore()
+	timed, err = s.CandidateAttempt(timed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timed.Status != models.AttemptExpired || timed.SubmittedAt == nil || !timed.SubmittedAt.Equal(*timed.ExpiresAt) || timed.Score != 0 {
+		t.Fatalf("got %+v", timed)
+	}
+
+	_, err = s.StartAttempt(userID, models.StartAttemptRequest{QuestionIDs: []int{foreign.ID}})
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.StartAttempt(otherID, models.StartAttemptRequest{TestID: test.ID})
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.StartAttempt(userID, models.StartAttemptRequest{TestID: test.ID, QuestionIDs: []int{first.ID}})
+	assertValidationError(t, err)
+	attempts, err := s.ListAttempts(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0].ID != attempt.ID || attempts[1].ID != timed.ID {
+		t.Fatalf("got %+v", attempts)
+	}
+	for _, listed := range attempts {
+		got, err := s.GetAttempt(listed.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(listed, got) {
+			t.Fatalf("listed %+v, got %+v", listed, got)
+		}
+	}
+
+	// attempts keep their questions as they were when they started
+	kept, err := s.StartAttempt(userID, models.StartAttemptRequest{QuestionIDs: []int{first.ID, third.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Update(first.ID, userID, models.Question{
+		Body:    "Where does the moon set?",
+		Options: []models.Option{{Body: "North", Correct: true}, {Body: "South"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Delete(third.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err = s.AnswerAttempt(kept.ID, models.AnswerRequest{QuestionID: first.ID, CheckRequest: west})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := kept.CurrentQuestion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.ID != third.ID || current.Body != third.Body || len(current.Options) != 2 {
+		t.Fatalf("got %+v", current)
+	}
+	kept, err = s.AnswerAttempt(kept.ID, models.AnswerRequest{
+		QuestionID:   third.ID,
+		CheckRequest: models.CheckRequest{OptionIDs: []int{third.Options[1].ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Score != 2 || !kept.Answers[0].Correct || !kept.Answers[1].Correct {
+		t.Fatalf("got %+v", kept)
+	}
+
+	// and when they are unshared
+	_, err = s.ShareQuestion(foreign.ID, otherID, models.ShareRequest{Username: "alice", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := s.StartAttempt(userID, models.StartAttemptRequest{QuestionIDs: []int{foreign.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RevokeShare(foreign.ID, userID, otherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err = s.AnswerAttempt(shared.ID, models.AnswerRequest{
+		QuestionID:   foreign.ID,
+		CheckRequest: models.CheckRequest{OptionIDs: []int{foreign.Options[1].ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Score != 1 || !shared.Answers[0].Correct {
+		t.Fatalf("got %+v", shared)
+	}
+
+	// listing closes attempts whose time ran out as well
+	expiring, err := s.StartAttempt(userID, models.StartAttemptRequest{QuestionIDs: []int{first.ID}, TimeLimit: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore = storage.SetNow(func() time.Time { return time.Now().Add(2 * time.Minute) })
+	attempts, err = s.ListAttempts(userID)
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := attempts[len(attempts)-1]
+	if listed.ID != expiring.ID || listed.Status != models.AttemptExpired || !listed.SubmittedAt.Equal(*listed.ExpiresAt) {
+		t.Fatalf("got %+v", listed)
+	}
+	expiring, err = s.GetAttempt(expiring.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listed, expiring) {
+		t.Fatalf("listed %+v, got %+v", listed, expiring)
+	}
+}
+
+func testRevisions(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	otherID := newUser(t, s, "bob")
+	question := newQuestion(t, s, userID)
+	east, west := question.Options[0], question.Options[1]
+
+	_, err := s.UpdateOption(models.Option{Body: "North"}, east.ID, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	question, err = s.AddOption(models.Option{Body: "South"}, question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	south := question.Options[2]
+	south.Correct = true
+	question.Body = "Where is it warm?"
+	question.Options = []models.Option{question.Options[0], south}
+	question, err = s.Update(question.ID, userID, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.DeleteOption(-1, question.ID, userID)
+	assertIs(t, err, storage.ErrNotFound)
+
+	revisions, err := s.ListRevisions(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 4 {
+		t.Fatalf("got %d revisions, want 4", len(revisions))
+	}
+	for i, revision := range revisions {
+		if revision.Number != i+1 || revision.AuthorID != userID || revision.QuestionID != question.ID || revision.CreatedAt.IsZero() {
+			t.Fatalf("got revision %+v", revision)
+		}
+	}
+	if revisions[0].Question.Body != "Where does the sun set?" || revisions[1].Question.Options[0].Body != "North" {
+		t.Fatalf("got snapshots %+v and %+v", revisions[0].Question, revisions[1].Question)
+	}
+	changes := revisions[0].Diff(revisions[3]).Changes
+	fields := make([]string, len(changes))
+	for i, change := range changes {
+		fields[i] = change.Field
+	}
+	want := []string{
+		"body",
+		fmt.Sprintf("options.%d.body", east.ID),
+		fmt.Sprintf("options.%d", west.ID),
+		fmt.Sprintf("options.%d", south.ID),
+	}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Fatalf("got changes %v, want %v", fields, want)
+	}
+
+	restored, err := s.RestoreRevision(question.ID, 1, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBodies(t, restored, "East", "West")
+	if restored.Body != "Where does the sun set?" || restored.Options[0].ID != east.ID || restored.Options[1].ID == west.ID {
+		t.Fatalf("got %+v", restored)
+	}
+	latest, err := s.GetRevision(question.ID, 5, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Question.Body != restored.Body {
+		t.Fatalf("got revision %+v", latest)
+	}
+	_, err = s.GetRevision(question.ID, 6, userID)
+	assertIs(t, err, storage.ErrNotFound)
+	_, err = s.ListRevisions(question.ID, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+	_, err = s.RestoreRevision(question.ID, 1, otherID)
+	assertIs(t, err, storage.ErrForbidden)
+}
+
+// testRevisionsByVersion checks that every change incrementing the version of a question stores a revision
+func testRevisionsByVersion(t *testing.T, s storage.Storage) {
+	userID := newUser(t, s, "alice")
+	question := newQuestion(t, s, userID)
+	assertRevised := func(check func(snapshot models.Question) bool) {
+		t.Helper()
+		current, err := s.Get(question.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revisions, err := s.ListRevisions(question.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		latest := revisions[len(revisions)-1]
+		if len(revisions) != current.Version || latest.Number != current.Version || latest.Question.Version != current.Version {
+			t.Fatalf("got %d revisions, latest %+v, want one per version %d", len(revisions), latest, current.Version)
+		}
+		if !check(latest.Question) {
+			t.Fatalf("got snapshot %+v", latest.Question)
+		}
+	}
+
+	question.Tags = []string{"space"}
+	question, err := s.Update(question.ID, userID, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRevised(func(snapshot models.Question) bool {
+		return strings.Join(snapshot.Tags, ",") == "space"
+	})
+	tags, err := s.ListTags(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.UpdateTag(tags[0].ID, userID, models.Tag{Name: "astronomy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRevised(func(snapshot models.Question) bool {
+		return strings.Join(snapshot.Tags, ",") == "astronomy"
+	})
+	err = s.DeleteTag(tags[0].ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRevised(func(snapshot models.Question) bool {
+		return len(snapshot.Tags) == 0
+	})
+
+	err = s.Delete(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.RestoreQuestion(question.ID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRevised(func(snapshot models.Question) bool {
+		return snapshot.DeletedAt == nil && snapshot.Version == restored.Version
+	})
+	deleted, err := s.GetRevision(question.ID, restored.Version-1, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Question.DeletedAt == nil || deleted.Question.Version != restored.Version-1 {
+		t.Fatalf("got revision %+v, want the deleted question", deleted)
+	}
+}
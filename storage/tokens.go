@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/makupi/backend-homework/models"
+	"strconv"
+	"time"
+)
+
+// Scope claims of delivery and attempt tokens, access tokens have no scope
+const (
+	TokenScopeDelivery = "delivery"
+	TokenScopeAttempt  = "attempt"
+)
+
+// attemptTokenGrace is how long attempt tokens outlive the time limit, so candidates can still see the attempt closed
+const attemptTokenGrace = 5 * time.Minute
+
+// TokenConfig holds everything needed to issue access, refresh and delivery tokens
+// DeliveryTTL is the default and maximum lifetime of delivery tokens
+type TokenConfig struct {
+	Secret      []byte
+	Issuer      string
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	DeliveryTTL time.Duration
+}
+
+// newAccessToken creates a signed JWT access token for userID
+// It returns the token together with its jti and expiry
+func newAccessToken(userID int, config TokenConfig) (token string, jti string, expiresAt time.Time, err error) {
+	jti, err = randomToken(16)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	expiresAt = now.Add(config.AccessTTL)
+	claims := jwt.MapClaims{
+		"userID": userID,
+		"iss":    config.Issuer,
+		"iat":    now.Unix(),
+		"exp":    expiresAt.Unix(),
+		"jti":    jti,
+	}
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.Secret)
+	return
+}
+
+// newTokenResponse creates a new access token and the response containing it and refreshToken
+func newTokenResponse(userID int, refreshToken string, config TokenConfig) (models.JWTTokenResponse, error) {
+	token, _, _, err := newAccessToken(userID, config)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return models.JWTTokenResponse{
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(config.AccessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// NewDeliveryToken creates a signed JWT delivery token for scope that expires after ttl
+// Delivery tokens carry no userID, so they are rejected everywhere but on the delivery endpoints
+func NewDeliveryToken(scope models.DeliveryScope, ttl time.Duration, config TokenConfig) (models.DeliveryTokenResponse, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return models.DeliveryTokenResponse{}, err
+	}
+	now := time.Now()
+	// the seed is a string because a JSON number would lose precision above 2^53
+	claims := jwt.MapClaims{
+		"scope":        TokenScopeDelivery,
+		"authorID":     scope.AuthorID,
+		"question_ids": scope.QuestionIDs,
+		"seed":         strconv.FormatInt(scope.Seed, 10),
+		"iss":          config.Issuer,
+		"iat":          now.Unix(),
+		"exp":          now.Add(ttl).Unix(),
+		"jti":          jti,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.Secret)
+	if err != nil {
+		return models.DeliveryTokenResponse{}, err
+	}
+	return models.DeliveryTokenResponse{
+		Token:       token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		QuestionIDs: scope.QuestionIDs,
+		Seed:        scope.Seed,
+	}, nil
+}
+
+// NewAttemptToken creates a signed JWT attempt token for the candidate of attempt
+// It expires shortly after the time limit of the attempt or after DeliveryTTL if there is none
+func NewAttemptToken(attempt models.Attempt, config TokenConfig) (models.AttemptTokenResponse, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return models.AttemptTokenResponse{}, err
+	}
+	now := time.Now()
+	ttl := config.DeliveryTTL
+	if attempt.ExpiresAt != nil {
+		ttl = attempt.ExpiresAt.Sub(now) + attemptTokenGrace
+	}
+	claims := jwt.MapClaims{
+		"scope":     TokenScopeAttempt,
+		"attemptID": attempt.ID,
+		"iss":       config.Issuer,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+		"jti":       jti,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.Secret)
+	if err != nil {
+		return models.AttemptTokenResponse{}, err
+	}
+	return models.AttemptTokenResponse{
+		Attempt:   attempt,
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresIn: int(ttl.Seconds()),
+	}, nil
+}
+
+// RandomSeed returns a random, non-negative seed for shuffling options
+func RandomSeed() (int64, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b) >> 1), nil
+}
+
+// randomToken returns n random bytes encoded as URL safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the hex encoded SHA-256 of a refresh token
+// Only the hash is persisted so a leaked database doesn't leak usable refresh tokens
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}